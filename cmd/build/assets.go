@@ -0,0 +1,72 @@
+package build
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/minifier"
+)
+
+// function ProcessAssets runs the optional steps on the collected
+// static files: minification, then fingerprinting so that hashes are
+// of the minified files. Run it after CollectStatic.
+func ProcessAssets(c *config.Config) error {
+	if c.Build.Minify {
+		if err := MinifyAssets(c); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := Fingerprint(c)
+	if err != nil {
+		return err
+	}
+
+	if len(manifest) > 0 {
+		BuildLogger.Infof("fingerprinted %v assets", len(manifest))
+	}
+
+	return nil
+}
+
+// function MinifyAssets minifies the stylesheets & scripts in the
+// static path in place. Files that are already minified (*.min.css
+// & *.min.js) are skipped.
+func MinifyAssets(c *config.Config) error {
+	dir := c.Build.GetStaticPath()
+	err := filepath.WalkDir(dir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+
+		minified, ok, err := minifier.MinifyFile(d.Name(), data)
+		if err != nil {
+			return fmt.Errorf("%v %w", fp, err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(fp, minified, info.Mode().Perm())
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to minify assets in %v %w", dir, err)
+	}
+
+	return nil
+}
@@ -19,7 +19,9 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/charmbracelet/log"
 	"github.com/desertthunder/documango/internal/config"
@@ -45,7 +47,7 @@ var failBuildAndExit func(msg string) = func(msg string) {
 }
 
 func createStaticBuildDir(c *config.Config) string {
-	dest := utils.CreateDir(c.Options.BuildDir + "/assets")
+	dest := utils.CreateDir(c.Build.BuildDir + "/assets")
 	BuildLogger.Debugf("created directory %v", dest)
 	return dest
 }
@@ -65,17 +67,15 @@ func CopyStaticFiles(c *config.Config) ([]*FilePath, error) {
 		return paths, fmt.Errorf("unable to read directory %v %w", src, err)
 	}
 
-	for _, entry := range entries {
-		fname := entry.Name()
-		if entry.IsDir() {
-			continue
+	if len(entries) > 0 {
+		copied, err := copyDir(src, dest, utils.NewIgnore(c.Build.Exclude), nil)
+		paths = append(paths, copied...)
+		if err != nil {
+			return paths, err
 		}
-
-		path, _ := utils.CopyFile(fname, src, dest)
-		paths = append(paths, &FilePath{path, fname})
 	}
 
-	theme, err := theme.BuildTheme()
+	theme, err := theme.BuildTheme(c.Theme.Light, c.Theme.Dark)
 	if err != nil && theme == "" {
 		return paths, err
 	} else if err != nil {
@@ -95,8 +95,87 @@ func CopyStaticFiles(c *config.Config) ([]*FilePath, error) {
 	return paths, nil
 }
 
+// function CopyContentFiles copies the files in the content directory
+// that aren't markdown (ex. images next to the page that uses them) to
+// the same relative path in the build directory, where pages linking
+// to them relatively will find them.
+func CopyContentFiles(c *config.Config) ([]*FilePath, error) {
+	src := c.Options.ContentDir
+	if _, err := os.Stat(src); err != nil {
+		return []*FilePath{}, nil
+	}
+
+	// The template & build directories can be nested in the content
+	// directory but are never content themselves
+	skip := map[string]bool{}
+	for _, d := range []string{c.Options.TemplateDir, c.Build.BuildDir} {
+		if abs, err := filepath.Abs(d); err == nil {
+			skip[abs] = true
+		}
+	}
+
+	return copyDir(src, c.Build.BuildDir, utils.NewIgnore(c.Build.Exclude), func(fp string, d fs.DirEntry) bool {
+		if d.IsDir() {
+			abs, err := filepath.Abs(fp)
+			return err == nil && skip[abs]
+		}
+
+		return !utils.IsNotMarkdown(d.Name())
+	})
+}
+
+// function copyDir copies the directory tree at src to dest, keeping
+// file modes. Paths matched by ignore or skip aren't copied. The Name
+// of each copied file is its slash separated path relative to dest.
+func copyDir(src, dest string, ignore *utils.Ignore, skip func(fp string, d fs.DirEntry) bool) ([]*FilePath, error) {
+	paths := []*FilePath{}
+	err := filepath.WalkDir(src, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, fp)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if ignore.Match(rel, d.IsDir()) || (skip != nil && skip(fp, d)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			return os.MkdirAll(filepath.Join(dest, rel), info.Mode().Perm()|0700)
+		}
+
+		path, err := utils.CopyFile(rel, src, dest)
+		if err != nil {
+			return err
+		}
+
+		BuildLogger.Debugf("copied %v to %v", rel, dest)
+		paths = append(paths, &FilePath{FileP: path, Name: rel})
+		return nil
+	})
+
+	if err != nil {
+		return paths, fmt.Errorf("unable to copy %v to %v %w", src, dest, err)
+	}
+
+	return paths, nil
+}
+
 func CollectStatic(c *config.Config) ([]*FilePath, error) {
-	b := c.Options.BuildDir
+	b := c.Build.BuildDir
 	defer BuildLogger.Infof("copied static files from %v to %v", c.Options.StaticDir, b)
 	static_paths, err := CopyStaticFiles(c)
 
@@ -110,7 +189,7 @@ func CollectStatic(c *config.Config) ([]*FilePath, error) {
 		}
 	}
 
-	theme, err := theme.BuildTheme()
+	theme, err := theme.BuildTheme(c.Theme.Light, c.Theme.Dark)
 	if err != nil && theme == "" {
 		return static_paths, err
 	} else if err != nil {
@@ -132,7 +211,7 @@ func CopyJS(conf *config.Config) error {
 
 	if err != nil {
 		if os.IsNotExist(err) {
-			fpath := fmt.Sprintf("%v/assets/theme.js", conf.Options.BuildDir)
+			fpath := fmt.Sprintf("%v/assets/theme.js", conf.Build.BuildDir)
 			f, err := os.Create(fpath)
 
 			if err != nil {
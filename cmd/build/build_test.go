@@ -8,11 +8,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/charmbracelet/log"
 	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
 	"github.com/desertthunder/documango/internal/utils"
 	"github.com/desertthunder/documango/internal/view"
 )
@@ -20,7 +22,7 @@ import (
 func setupConf() (string, string, *config.Config) {
 	root := utils.FindWDRoot()
 	base_path := fmt.Sprintf("%v/example", root)
-	conf := config.OpenConfig(fmt.Sprintf("%v/%v", base_path, "config.toml"))
+	conf, _ := config.OpenConfig(fmt.Sprintf("%v/%v", base_path, "config.toml"))
 	return root, base_path, conf
 }
 
@@ -28,7 +30,7 @@ func mutateConf(conf *config.Config, contextDir string) {
 	root := utils.FindWDRoot()
 	base_path := fmt.Sprintf("%v/example", root)
 
-	conf.Options.BuildDir = fmt.Sprintf("%v/%v/%v", base_path, conf.Options.BuildDir, contextDir)
+	conf.Build.BuildDir = fmt.Sprintf("%v/%v/%v", base_path, conf.Build.BuildDir, contextDir)
 	conf.Options.TemplateDir = fmt.Sprintf("%v/%v", base_path, conf.Options.TemplateDir)
 	conf.Options.ContentDir = fmt.Sprintf("%v/%v", base_path, conf.Options.ContentDir)
 	conf.Options.StaticDir = fmt.Sprintf("%v/%v", base_path, conf.Options.StaticDir)
@@ -62,11 +64,11 @@ func TestBuild(t *testing.T) {
 			t.Error("static directory not updated")
 		}
 
-		if opts.BuildDir == defaultOpts.BuildDir {
+		if conf.Build.BuildDir == defaultConf.Build.BuildDir {
 			t.Error("build directory not updated")
 		}
 
-		if sp := opts.GetStaticPath(); sp == defaultOpts.GetStaticPath() {
+		if sp := conf.Build.GetStaticPath(); sp == defaultConf.Build.GetStaticPath() {
 			t.Errorf("%v should have changed", sp)
 		}
 	})
@@ -90,7 +92,7 @@ func TestBuild(t *testing.T) {
 
 	t.Run("creates new views from content & template dir", func(t *testing.T) {
 		var err error
-		views, err = view.NewViews(conf.Options.ContentDir, conf.Options.TemplateDir)
+		views, err = view.NewViews(conf.Options.ContentDir, conf.Options.TemplateDir, md.Preview{})
 		if err != nil && len(views) == 0 {
 			t.Fatalf("unable to build views %v", err.Error())
 		}
@@ -195,7 +197,7 @@ func TestBuild(t *testing.T) {
 			t.Errorf("command should run %v %v", args, err.Error())
 		}
 
-		dir := conf.Options.BuildDir
+		dir := conf.Build.BuildDir
 
 		d, err := os.Stat(dir)
 
@@ -257,7 +259,7 @@ func TestBuild(t *testing.T) {
 			}
 
 			if !has_js {
-				_, err := os.ReadFile(fmt.Sprintf("%v/%v", conf.Options.BuildDir, "theme.js"))
+				_, err := os.ReadFile(fmt.Sprintf("%v/%v", conf.Build.BuildDir, "theme.js"))
 
 				if err != nil && err == os.ErrNotExist {
 					t.Errorf("the script should have been copied but it was not %v", err.Error())
@@ -269,6 +271,151 @@ func TestBuild(t *testing.T) {
 		})
 	})
 
+	t.Run("copies static files recursively, skipping excluded paths", func(t *testing.T) {
+		dir := t.TempDir()
+		c := config.NewDefaultConfig()
+		c.Options.StaticDir = fmt.Sprintf("%v/static", dir)
+		c.Build.BuildDir = fmt.Sprintf("%v/dist", dir)
+		c.Build.Exclude = []string{"*.psd", "raw/", "!keep.psd"}
+
+		for name, mode := range map[string]os.FileMode{
+			"images/logo.png":      0644,
+			"images/logo.psd":      0644,
+			"images/keep.psd":      0644,
+			"bin/run.sh":           0755,
+			"fonts/raw/font.woff2": 0644,
+		} {
+			fp := fmt.Sprintf("%v/%v", c.Options.StaticDir, name)
+			os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+			if err := os.WriteFile(fp, []byte(name), mode); err != nil {
+				t.Fatalf("unable to write %v %v", name, err.Error())
+			}
+
+			os.Chmod(fp, mode)
+		}
+
+		if _, err := CopyStaticFiles(&c); err != nil {
+			t.Fatalf("unable to copy static files %v", err.Error())
+		}
+
+		assets := fmt.Sprintf("%v/assets", c.Build.BuildDir)
+		for _, name := range []string{"images/logo.png", "images/keep.psd", "bin/run.sh"} {
+			if _, err := os.Stat(fmt.Sprintf("%v/%v", assets, name)); err != nil {
+				t.Errorf("%v should have been copied %v", name, err.Error())
+			}
+		}
+
+		for _, name := range []string{"images/logo.psd", "fonts/raw"} {
+			if _, err := os.Stat(fmt.Sprintf("%v/%v", assets, name)); err == nil {
+				t.Errorf("%v should have been excluded", name)
+			}
+		}
+
+		if info, err := os.Stat(fmt.Sprintf("%v/bin/run.sh", assets)); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("file modes should be preserved, got %v", info.Mode())
+		}
+	})
+
+	t.Run("copies non-markdown content files next to their pages", func(t *testing.T) {
+		paths, err := CopyContentFiles(conf)
+		if err != nil {
+			t.Fatalf("unable to copy content files %v", err.Error())
+		}
+
+		if _, err := os.Stat(fmt.Sprintf("%v/not-md.txt", conf.Build.BuildDir)); err != nil {
+			t.Errorf("not-md.txt should have been copied %v", err.Error())
+		}
+
+		for _, p := range paths {
+			if strings.HasSuffix(p.Name, ".md") {
+				t.Errorf("markdown files should not be copied %v", p.Name)
+			}
+		}
+	})
+
+	t.Run("fingerprints assets & writes a manifest", func(t *testing.T) {
+		c := config.NewDefaultConfig()
+		c.Build.BuildDir = t.TempDir()
+		c.Build.Fingerprint = true
+
+		assets := c.Build.GetStaticPath()
+		os.MkdirAll(fmt.Sprintf("%v/fonts", assets), os.ModePerm)
+		os.MkdirAll(fmt.Sprintf("%v/images", assets), os.ModePerm)
+		os.MkdirAll(fmt.Sprintf("%v/_images", assets), os.ModePerm)
+		os.WriteFile(fmt.Sprintf("%v/styles.css", assets), []byte("body {}"), 0644)
+		os.WriteFile(fmt.Sprintf("%v/fonts/mono.woff2", assets), []byte("font"), 0644)
+		os.WriteFile(fmt.Sprintf("%v/images/photo.png", assets), []byte("photo"), 0644)
+		os.WriteFile(fmt.Sprintf("%v/_images/logo-1a2b3c4d.png", assets), []byte("png"), 0644)
+
+		manifest, err := Fingerprint(&c)
+		if err != nil {
+			t.Fatalf("unable to fingerprint assets %v", err.Error())
+		}
+
+		hashed := manifest["styles.css"]
+		if !strings.HasPrefix(hashed, "styles.") || !strings.HasSuffix(hashed, ".css") || len(hashed) != len("styles.12345678.css") {
+			t.Errorf("got %v, want styles.{hash}.css", hashed)
+		}
+
+		if !strings.HasPrefix(manifest["fonts/mono.woff2"], "fonts/mono.") {
+			t.Errorf("nested assets should be fingerprinted %v", manifest)
+		}
+
+		if _, ok := manifest["_images/logo-1a2b3c4d.png"]; ok {
+			t.Error("processed images should not be fingerprinted again")
+		}
+
+		if !strings.HasPrefix(manifest["images/photo.png"], "images/photo.") {
+			t.Errorf("static images should be fingerprinted %v", manifest)
+		}
+
+		for _, name := range []string{hashed, "styles.css", ManifestFile} {
+			if _, err := os.Stat(fmt.Sprintf("%v/%v", assets, name)); err != nil {
+				t.Errorf("%v should exist %v", name, err.Error())
+			}
+		}
+
+		c.Build.Fingerprint = false
+		if _, err := Fingerprint(&c); err != nil {
+			t.Fatalf("unable to clean up fingerprints %v", err.Error())
+		}
+
+		for _, name := range []string{hashed, ManifestFile} {
+			if _, err := os.Stat(fmt.Sprintf("%v/%v", assets, name)); err == nil {
+				t.Errorf("%v from the previous build should be removed", name)
+			}
+		}
+	})
+
+	t.Run("minifies assets before fingerprinting them", func(t *testing.T) {
+		c := config.NewDefaultConfig()
+		c.Build.BuildDir = t.TempDir()
+		c.Build.Minify = true
+		c.Build.Fingerprint = true
+
+		assets := c.Build.GetStaticPath()
+		os.MkdirAll(assets, os.ModePerm)
+		os.WriteFile(fmt.Sprintf("%v/styles.css", assets), []byte("body {\n  margin: 0px;\n}\n"), 0644)
+		os.WriteFile(fmt.Sprintf("%v/vendor.min.js", assets), []byte("var a = 1;\n"), 0644)
+
+		if err := ProcessAssets(&c); err != nil {
+			t.Fatalf("unable to process assets %v", err.Error())
+		}
+
+		css, _ := os.ReadFile(fmt.Sprintf("%v/styles.css", assets))
+		if string(css) != "body{margin:0}" {
+			t.Errorf("got %v, want body{margin:0}", string(css))
+		}
+
+		if js, _ := os.ReadFile(fmt.Sprintf("%v/vendor.min.js", assets)); string(js) != "var a = 1;\n" {
+			t.Errorf("minified files should be untouched, got %v", string(js))
+		}
+
+		if _, err := os.Stat(fmt.Sprintf("%v/%v", assets, fingerprintName("styles.css", css))); err != nil {
+			t.Errorf("the fingerprint should be of the minified file %v", err.Error())
+		}
+	})
+
 	t.Run("error states", func(t *testing.T) {
 		sb := strings.Builder{}
 		failBuildAndExit = func(msg string) {
@@ -3,10 +3,12 @@ package build
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/desertthunder/documango/internal/config"
 	"github.com/desertthunder/documango/internal/logs"
+	"github.com/desertthunder/documango/internal/minifier"
 	"github.com/desertthunder/documango/internal/view"
 	"github.com/urfave/cli/v3"
 )
@@ -14,16 +16,24 @@ import (
 var BuildLogger *log.Logger = logs.CreateConsoleLogger("[build]")
 
 var BuildCommand = &cli.Command{
-	Name:   "build",
-	Usage:  "build your site to your configured directory (defaults to dist)",
-	Flags:  config.BuildFlags(true),
+	Name:  "build",
+	Usage: "build your site to your configured directory (defaults to dist)",
+	Flags: append(append(config.BuildFlags(true),
+		&cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail the build on warnings, like links to missing files",
+		}), config.PreviewFlags()...),
+	Before: config.ApplyFlags,
 	Action: Run,
 }
 
 func Run(ctx context.Context, c *cli.Command) error {
 	BuildLogger = ctx.Value(config.LoggerKey).(*log.Logger)
 	conf := ctx.Value(config.ConfKey).(*config.Config)
-	views, err := view.NewViews(conf.Options.ContentDir, conf.Options.TemplateDir)
+
+	minifier.Reset()
+
+	views, err := view.NewViews(conf.Options.ContentDir, conf.Options.TemplateDir, view.PreviewConfig(conf))
 	if err != nil && len(views) > 0 {
 		BuildLogger.Warn(err.Error())
 	}
@@ -42,6 +52,21 @@ func Run(ctx context.Context, c *cli.Command) error {
 		BuildLogger.Info("collected static files ✅")
 	}
 
+	if err := ProcessAssets(conf); err != nil {
+		return err
+	}
+
+	if _, err := CopyContentFiles(conf); err != nil {
+		return fmt.Errorf("unable to copy content files %w", err)
+	}
+
+	opts, err := conf.Markdown.Parse()
+	if err != nil {
+		return fmt.Errorf("unable to read markdown options %w", err)
+	}
+
+	views = view.WithImages(view.WithOptions(views, opts), view.NewImageProcessor(conf))
+
 	for _, v := range views {
 		logs.Pause(level)
 
@@ -49,12 +74,24 @@ func Run(ctx context.Context, c *cli.Command) error {
 			return fmt.Errorf("unable to build view %v %w", v.Path, err)
 		}
 
-		BuildLogger.Infof("built page %v.html (%v)", v.Path, v.Name())
+		for _, w := range v.Warnings {
+			BuildLogger.Warn(w.Error())
+		}
+
+		if len(v.Preview) > 0 {
+			BuildLogger.Warnf("built %v page %v.html (%v)", strings.Join(v.Preview, " & "), v.Path, v.Name())
+		} else {
+			BuildLogger.Infof("built page %v.html (%v)", v.Path, v.Name())
+		}
 	}
 
 	logs.Pause(level)
 
-	BuildLogger.Infof("built site to %v ✅", conf.Options.BuildDir)
+	for _, saved := range minifier.Report() {
+		BuildLogger.Infof("minified %v", saved)
+	}
+
+	BuildLogger.Infof("built site to %v ✅", conf.Build.BuildDir)
 
 	return nil
 }
@@ -0,0 +1,112 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/images"
+	"github.com/desertthunder/documango/internal/utils"
+)
+
+// ManifestFile maps logical asset names to their fingerprinted names.
+// It's written to the static path ({build_dir}/assets).
+const ManifestFile = "manifest.json"
+
+// function Fingerprint copies every collected asset to a name that
+// contains a hash of its contents (ex. styles.css -> styles.5d41402a.css)
+// and writes the mapping to the manifest, which the asset template func
+// reads. Run it after CollectStatic.
+//
+// Originals are kept so that hard-coded references (ex. /assets/ links
+// in markdown or url()s in stylesheets) still resolve. Copies from a
+// previous build are removed first and, when fingerprinting is disabled,
+// so is the manifest.
+func Fingerprint(c *config.Config) (map[string]string, error) {
+	dir := c.Build.GetStaticPath()
+	manifestPath := filepath.Join(dir, ManifestFile)
+	manifest := map[string]string{}
+
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		previous := map[string]string{}
+		json.Unmarshal(data, &previous)
+		for _, hashed := range previous {
+			os.Remove(filepath.Join(dir, filepath.FromSlash(hashed)))
+		}
+
+		os.Remove(manifestPath)
+	}
+
+	if !c.Build.Fingerprint {
+		return manifest, nil
+	}
+
+	// Processed images already have a hash in their name
+	skip := strings.TrimPrefix(images.OutputDir, "assets/")
+
+	err := filepath.WalkDir(dir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == skip {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+
+		hashed := fingerprintName(rel, data)
+		if err := utils.CreateAndWriteFile(data, filepath.Join(dir, filepath.FromSlash(hashed))); err != nil {
+			return err
+		}
+
+		BuildLogger.Debugf("fingerprinted %v as %v", rel, hashed)
+		manifest[rel] = hashed
+		return nil
+	})
+
+	if err != nil {
+		return manifest, fmt.Errorf("unable to fingerprint assets in %v %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, fmt.Errorf("unable to encode manifest %w", err)
+	}
+
+	if err := utils.CreateAndWriteFile(data, manifestPath); err != nil {
+		return manifest, fmt.Errorf("unable to write manifest %w", err)
+	}
+
+	return manifest, nil
+}
+
+// function fingerprintName inserts the first 8 characters of the
+// sha256 hash of data before the extension of name
+func fingerprintName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])[:8]
+	ext := path.Ext(name)
+
+	return fmt.Sprintf("%v.%v%v", strings.TrimSuffix(name, ext), hash, ext)
+}
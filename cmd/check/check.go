@@ -0,0 +1,325 @@
+/*
+package check builds a site and crawls every generated page to find
+links that don't resolve.
+
+Pages are rendered in memory while static files are collected into a
+temporary build directory. Each href & src (internal links, anchors,
+images, scripts & stylesheets) is then resolved against the built pages
+and files. External links are only checked against the allowlist in the
+[check] table of config.toml, since builds can't rely on the network.
+*/
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/desertthunder/documango/cmd/build"
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/view"
+)
+
+var (
+	tagPattern  = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+	attrPattern = regexp.MustCompile(`\s([a-zA-Z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// type Problem is a reference in a page that doesn't resolve
+type Problem struct {
+	Target string
+	Reason string
+}
+
+// type Report maps a content file to the problems found in the page
+// built from it
+type Report map[string][]Problem
+
+// type page is a rendered page and the ids of the elements it contains
+type page struct {
+	source string
+	route  string
+	html   []byte
+	ids    map[string]bool
+}
+
+type Checker struct {
+	Config   *config.Config
+	pages    map[string]*page
+	buildDir string
+}
+
+// function NewChecker creates a checker for the site described by
+// conf. Call Build before Check.
+func NewChecker(conf *config.Config) *Checker {
+	return &Checker{Config: conf, pages: map[string]*page{}}
+}
+
+// function Build renders every page in memory and collects the
+// site's static files into a temporary directory. Call Close to
+// remove it.
+func (c *Checker) Build() error {
+	dir, err := os.MkdirTemp("", "documango-check-")
+	if err != nil {
+		return fmt.Errorf("unable to create build dir %w", err)
+	}
+
+	c.buildDir = dir
+
+	conf := *c.Config
+	conf.Build.BuildDir = dir
+
+	if _, err := build.CollectStatic(&conf); err != nil {
+		return fmt.Errorf("unable to collect static files %w", err)
+	}
+
+	if err := build.ProcessAssets(&conf); err != nil {
+		return err
+	}
+
+	if _, err := build.CopyContentFiles(&conf); err != nil {
+		return fmt.Errorf("unable to copy content files %w", err)
+	}
+
+	views, err := view.NewViews(conf.Options.ContentDir, conf.Options.TemplateDir, view.PreviewConfig(&conf))
+	if err != nil && len(views) == 0 {
+		return err
+	}
+
+	opts, err := conf.Markdown.Parse()
+	if err != nil {
+		return fmt.Errorf("unable to read markdown options %w", err)
+	}
+
+	views = view.WithImages(view.WithOptions(views, opts), view.NewImageProcessor(&conf))
+
+	for _, v := range views {
+		v.GetTemplate()
+
+		b := bytes.Buffer{}
+		if err := v.Render(&b, &conf); err != nil {
+			return fmt.Errorf("unable to render %v %w", v.Markdown.FilePath, err)
+		}
+
+		p := &page{source: v.Markdown.FilePath, route: v.Route(), html: b.Bytes()}
+		p.ids = ids(p.html)
+		c.pages[p.route] = p
+	}
+
+	return nil
+}
+
+// function Close removes the temporary build directory
+func (c *Checker) Close() error {
+	if c.buildDir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(c.buildDir)
+}
+
+// function Check crawls every built page and reports references that
+// don't resolve to a page, an element on a page or a static file
+func (c *Checker) Check() Report {
+	report := Report{}
+
+	for _, p := range c.pages {
+		for _, ref := range references(p.html) {
+			if reason := c.resolve(p, ref); reason != "" {
+				report[p.source] = append(report[p.source], Problem{Target: ref, Reason: reason})
+			}
+		}
+	}
+
+	return report
+}
+
+// function resolve returns why ref (found on page p) is broken or an
+// empty string if it isn't
+func (c *Checker) resolve(p *page, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return fmt.Sprintf("invalid url: %v", err.Error())
+	}
+
+	switch u.Scheme {
+	case "mailto", "tel", "data", "javascript":
+		return ""
+	case "http", "https", "":
+		if u.Host != "" && !c.isSite(u) {
+			return c.resolveExternal(u)
+		}
+	default:
+		return c.resolveExternal(u)
+	}
+
+	base, _ := url.Parse(p.route)
+	target := base.ResolveReference(u)
+	route := c.trimBasePath(target.Path)
+
+	if linked, ok := c.page(route); ok {
+		return c.resolveFragment(linked, u.Fragment)
+	}
+
+	if c.fileExists(route) {
+		return ""
+	}
+
+	return "not found"
+}
+
+func (c *Checker) resolveFragment(p *page, fragment string) string {
+	if fragment == "" || p.ids[fragment] {
+		return ""
+	}
+
+	return fmt.Sprintf("anchor #%v not found on %v", fragment, p.route)
+}
+
+// function resolveExternal checks a link to another site against
+// the allowlist. Without one, external links are skipped.
+func (c *Checker) resolveExternal(u *url.URL) string {
+	allow := c.Config.Check.Allow
+	if len(allow) == 0 {
+		return ""
+	}
+
+	s := u.String()
+	for _, a := range allow {
+		if strings.HasPrefix(s, a) || u.Host == a {
+			return ""
+		}
+	}
+
+	return "external link not in allowlist"
+}
+
+// function isSite reports whether u is an absolute link into the site
+// at meta.URL (ex. from absURL), which is checked like an internal link
+func (c *Checker) isSite(u *url.URL) bool {
+	site, err := url.Parse(c.Config.Metadata.URL)
+	if err != nil || site.Host == "" || !strings.EqualFold(u.Host, site.Host) {
+		return false
+	}
+
+	base := strings.TrimSuffix(site.Path, "/")
+	return base == "" || u.Path == base || strings.HasPrefix(u.Path, base+"/")
+}
+
+// function page finds the page served at route, accepting the
+// file names a static host would also serve it from
+func (c *Checker) page(route string) (*page, bool) {
+	candidates := []string{
+		route,
+		strings.TrimSuffix(route, ".html"),
+		strings.TrimSuffix(route, "index.html"),
+		strings.TrimSuffix(route, "/"),
+		route + "/",
+	}
+
+	for _, r := range candidates {
+		if r == "" {
+			r = "/"
+		}
+
+		if p, ok := c.pages[r]; ok {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *Checker) fileExists(route string) bool {
+	clean := path.Clean("/" + route)
+	info, err := os.Stat(c.buildDir + clean)
+	return err == nil && !info.IsDir()
+}
+
+// function trimBasePath removes the path portion of meta.URL, which
+// relURL & asset prefix their output with
+func (c *Checker) trimBasePath(p string) string {
+	u, err := url.Parse(c.Config.Metadata.URL)
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return p
+	}
+
+	base := strings.TrimSuffix(u.Path, "/")
+	if p == base || strings.HasPrefix(p, base+"/") {
+		return "/" + strings.TrimPrefix(strings.TrimPrefix(p, base), "/")
+	}
+
+	return p
+}
+
+// function Write prints the report grouped by file and returns the
+// total number of problems
+func (r Report) Write(w io.Writer) int {
+	files := make([]string, 0, len(r))
+	for f := range r {
+		files = append(files, f)
+	}
+
+	sort.Strings(files)
+
+	total := 0
+	for _, f := range files {
+		fmt.Fprintf(w, "%v\n", f)
+		for _, p := range r[f] {
+			fmt.Fprintf(w, "  %v: %v\n", p.Target, p.Reason)
+			total++
+		}
+	}
+
+	return total
+}
+
+// function references lists the urls in the href, src & srcset
+// attributes of every tag in doc
+func references(doc []byte) []string {
+	refs := []string{}
+	for _, tag := range tagPattern.FindAll(doc, -1) {
+		for _, attr := range attrPattern.FindAllSubmatch(tag, -1) {
+			name := strings.ToLower(string(attr[1]))
+			value := html.UnescapeString(string(attr[2]) + string(attr[3]))
+
+			switch name {
+			case "href", "src":
+				if value != "" {
+					refs = append(refs, value)
+				}
+			case "srcset":
+				for _, candidate := range strings.Split(value, ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						refs = append(refs, fields[0])
+					}
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// function ids collects the id & name attributes in doc, the
+// targets of #fragment links
+func ids(doc []byte) map[string]bool {
+	found := map[string]bool{}
+	for _, tag := range tagPattern.FindAll(doc, -1) {
+		for _, attr := range attrPattern.FindAllSubmatch(tag, -1) {
+			name := strings.ToLower(string(attr[1]))
+			if name == "id" || name == "name" {
+				found[html.UnescapeString(string(attr[2])+string(attr[3]))] = true
+			}
+		}
+	}
+
+	return found
+}
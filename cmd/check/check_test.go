@@ -0,0 +1,176 @@
+package check
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/documango/cmd/build"
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/urfave/cli/v3"
+)
+
+func setupSite(t *testing.T, files map[string]string) *config.Config {
+	dir := t.TempDir()
+	for name, content := range files {
+		fp := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+		if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %v %v", name, err.Error())
+		}
+	}
+
+	conf := config.NewDefaultConfig()
+	conf.Options.ContentDir = filepath.Join(dir, "content")
+	conf.Options.StaticDir = filepath.Join(dir, "static")
+	conf.Options.TemplateDir = filepath.Join(dir, "templates")
+	conf.Build.BuildDir = filepath.Join(dir, "dist")
+
+	return &conf
+}
+
+func TestCheck(t *testing.T) {
+	sb := strings.Builder{}
+	build.BuildLogger = log.Default()
+	build.BuildLogger.SetOutput(&sb)
+
+	conf := setupSite(t, map[string]string{
+		"content/README.md": strings.Join([]string{
+			"# Home",
+			"",
+			"[about](./about.md#why) [setup](./guides/setup.md#building) [top](#home)",
+			"![logo](/assets/logo.png) [site](https://example.org/page)",
+		}, "\n"),
+		"content/about.md": strings.Join([]string{
+			"# About",
+			"",
+			"## Why",
+			"",
+			"[missing anchor](./README.md#nope) [missing page](./nope.md) ![img](/assets/nope.png)",
+		}, "\n"),
+		"content/guides/setup.md": "# Setup\n\n## Building\n\n[back](../README.md)",
+		"static/logo.png":         "png",
+		"static/theme.js":         "",
+	})
+
+	checker := NewChecker(conf)
+	defer checker.Close()
+
+	if err := checker.Build(); err != nil {
+		t.Fatalf("unable to build site %v", err.Error())
+	}
+
+	report := checker.Check()
+
+	t.Run("valid links, anchors & assets aren't reported", func(t *testing.T) {
+		for _, problems := range report {
+			for _, p := range problems {
+				for _, valid := range []string{"/about#why", "/guides/setup#building", "#home", "/assets/logo.png", "/"} {
+					if p.Target == valid {
+						t.Errorf("%v should resolve but was reported: %v", p.Target, p.Reason)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("broken links are reported for the file they're in", func(t *testing.T) {
+		var source string
+		for f := range report {
+			if strings.HasSuffix(f, "about.md") {
+				source = f
+			}
+		}
+
+		if source == "" {
+			t.Fatalf("about.md should have problems %v", report)
+		}
+
+		targets := []string{}
+		for _, p := range report[source] {
+			targets = append(targets, p.Target)
+		}
+
+		for _, want := range []string{"/#nope", "./nope.md", "/assets/nope.png"} {
+			found := false
+			for _, target := range targets {
+				found = found || target == want
+			}
+
+			if !found {
+				t.Errorf("%v should be reported in %v", want, targets)
+			}
+		}
+	})
+
+	t.Run("external links are skipped without an allowlist", func(t *testing.T) {
+		for _, problems := range report {
+			for _, p := range problems {
+				if strings.HasPrefix(p.Target, "https://") {
+					t.Errorf("%v should be skipped", p.Target)
+				}
+			}
+		}
+	})
+
+	t.Run("external links outside the allowlist are reported", func(t *testing.T) {
+		conf.Check.Allow = []string{"https://github.com"}
+		defer func() { conf.Check.Allow = nil }()
+
+		found := false
+		for _, problems := range checker.Check() {
+			for _, p := range problems {
+				found = found || p.Target == "https://example.org/page"
+			}
+		}
+
+		if !found {
+			t.Error("https://example.org/page should be reported")
+		}
+	})
+
+	t.Run("absolute links to the site are checked like internal links", func(t *testing.T) {
+		conf := setupSite(t, map[string]string{
+			"content/README.md": "# Home\n\n[why](https://docs.example.com/v1/about#why) [gone](https://docs.example.com/v1/nope) [other](https://docs.example.com/v2/page)",
+			"content/about.md":  "# About\n\n## Why",
+			"static/theme.js":   "",
+		})
+		conf.Metadata.URL = "https://docs.example.com/v1/"
+
+		checker := NewChecker(conf)
+		defer checker.Close()
+
+		if err := checker.Build(); err != nil {
+			t.Fatalf("unable to build site %v", err.Error())
+		}
+
+		targets := []string{}
+		for _, problems := range checker.Check() {
+			for _, p := range problems {
+				targets = append(targets, p.Target)
+			}
+		}
+
+		if len(targets) != 1 || targets[0] != "https://docs.example.com/v1/nope" {
+			t.Errorf("only the missing page should be reported, got %v", targets)
+		}
+	})
+
+	t.Run("report writes each file & fails the command", func(t *testing.T) {
+		w := strings.Builder{}
+		if total := report.Write(&w); total != 3 {
+			t.Errorf("there should be 3 problems, got %v\n%v", total, w.String())
+		}
+
+		ctx := context.WithValue(context.Background(), config.ConfKey, conf)
+		ctx = context.WithValue(ctx, config.LoggerKey, build.BuildLogger)
+
+		cmd := &cli.Command{Name: CheckCommand.Name, Writer: &w}
+		if err := Run(ctx, cmd); err == nil {
+			t.Error("check should fail when there are broken links")
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/desertthunder/documango/cmd/build"
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/logs"
+	"github.com/urfave/cli/v3"
+)
+
+var CheckLogger *log.Logger = logs.CreateConsoleLogger("[check]")
+
+var CheckCommand = &cli.Command{
+	Name:  "check",
+	Usage: "check the built site for broken links",
+	Description: strings.Join(
+		[]string{
+			"builds the site in memory and verifies that every link, anchor,",
+			"image and asset resolves. exits with a non-zero code when",
+			"something doesn't, so it can be used in CI.",
+		},
+		"\n",
+	),
+	Flags:  config.BuildFlags(true),
+	Before: config.ApplyFlags,
+	Action: Run,
+}
+
+func Run(ctx context.Context, c *cli.Command) error {
+	CheckLogger = ctx.Value(config.LoggerKey).(*log.Logger)
+	conf := ctx.Value(config.ConfKey).(*config.Config)
+
+	conf.UpdateLogLevel(CheckLogger)
+
+	// Static file collection is noisy and not what we're reporting on
+	build.BuildLogger = log.New(os.Stderr)
+	build.BuildLogger.SetLevel(log.ErrorLevel)
+
+	checker := NewChecker(conf)
+	defer checker.Close()
+
+	if err := checker.Build(); err != nil {
+		return fmt.Errorf("unable to build site %w", err)
+	}
+
+	CheckLogger.Infof("checking %v pages", len(checker.pages))
+
+	w := c.Root().Writer
+	if w == nil {
+		w = os.Stdout
+	}
+
+	if total := checker.Check().Write(w); total > 0 {
+		return fmt.Errorf("found %v broken references", total)
+	}
+
+	CheckLogger.Info("no broken links found ✅")
+
+	return nil
+}
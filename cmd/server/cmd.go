@@ -20,11 +20,12 @@ var ServerCommand = &cli.Command{
 		"\n",
 	),
 	ArgsUsage: "[config]",
-	Flags: config.MergeFlags(
+	Flags: append(config.MergeFlags(
 		&cli.IntFlag{
 			Name:     "port",
 			Aliases:  []string{"p", "addr"},
 			Required: false,
-		}, true),
+		}, true), config.PreviewFlags()...),
+	Before: config.ApplyFlags,
 	Action: Run,
 }
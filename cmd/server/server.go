@@ -5,11 +5,16 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,6 +22,7 @@ import (
 	"github.com/desertthunder/documango/cmd/build"
 	"github.com/desertthunder/documango/internal/config"
 	"github.com/desertthunder/documango/internal/logs"
+	"github.com/desertthunder/documango/internal/md"
 	"github.com/desertthunder/documango/internal/view"
 	"github.com/fsnotify/fsnotify"
 	"github.com/urfave/cli/v3"
@@ -25,11 +31,16 @@ import (
 var (
 	ServerLogger *log.Logger
 	stopSignal   = make(chan os.Signal, 1)
+	// errRestart is returned by listen when a config change needs a
+	// new listener & watcher, ex. a different port or content dir
+	errRestart = errors.New("restarting server")
 )
 
 type locks struct {
 	documentLoader *sync.RWMutex
 	serverStarter  *sync.RWMutex
+	// pendingChanges guards the changes recorded by the watcher
+	pendingChanges *sync.Mutex
 }
 
 type state struct {
@@ -37,6 +48,18 @@ type state struct {
 	canceller context.CancelFunc
 }
 
+// type snapshot is a build of the site: the config it was built with,
+// its views & the handler that serves them. Rebuilds create a new
+// snapshot that is swapped in atomically, so in-flight requests see a
+// consistent set of routes & rendered pages. Assets & content files
+// aren't copied, they're served from the build dir, which reloads
+// rewrite in place.
+type snapshot struct {
+	config  *config.Config
+	views   []*view.View
+	handler http.Handler
+}
+
 type server struct {
 	port        int32
 	contentDir  string
@@ -46,10 +69,27 @@ type server struct {
 	config      *config.Config
 	views       []*view.View
 	staticPaths []*build.FilePath
-	watcher     fsnotify.Watcher
-	locks       locks
-	handler     http.Handler
-	server      *http.Server
+	// non-markdown files copied from the content dir
+	contentPaths []*build.FilePath
+	watcher      fsnotify.Watcher
+	locks        locks
+	// handler is the entrypoint of every request, it dispatches to the
+	// current snapshot
+	handler http.Handler
+	current *atomic.Pointer[snapshot]
+	server  *http.Server
+	// configPaths are the config file & the overlay of the selected
+	// environment, watched for changes
+	configPaths []string
+	// openConfig re-reads the config, with the same layers as the
+	// command that started the server
+	openConfig func() (*config.Config, error)
+	// pending are the changes recorded by the watcher since the last
+	// rebuild
+	pending changes
+	// watching, if set, is closed by watchFiles once the source
+	// directories are watched
+	watching chan struct{}
 }
 
 // function createMachine creates a state machine that stores
@@ -70,13 +110,37 @@ func (s *server) createLocks() {
 
 	s.locks.documentLoader = &sync.RWMutex{}
 	s.locks.serverStarter = &sync.RWMutex{}
+	s.locks.pendingChanges = &sync.Mutex{}
 }
 
-// function addLoggingMiddleware adds logging middleware that wraps the mux instance
+// function addLoggingMiddleware adds logging middleware that wraps the
+// dispatcher to the current snapshot. It's added once, snapshots are
+// swapped underneath it.
 func (s *server) addLoggingMiddleware() {
 	ServerLogger.Debug("adding logger")
 
-	s.handler = logs.Middleware{Handler: s.handler, MLogger: ServerLogger}
+	s.handler = logs.Middleware{Handler: http.HandlerFunc(s.serveSnapshot), MLogger: ServerLogger}
+}
+
+// function serveSnapshot serves a request with the current snapshot
+func (s *server) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap := s.current.Load()
+	if snap == nil {
+		http.Error(w, "the site is still building", http.StatusServiceUnavailable)
+		return
+	}
+
+	snap.handler.ServeHTTP(w, r)
+}
+
+// function publish swaps in a snapshot of the views that have been
+// rendered, with routes for them & the static & content files
+func (s *server) publish() {
+	s.current.Store(&snapshot{
+		config:  s.config,
+		views:   slices.Clone(s.views),
+		handler: s.routes(),
+	})
 }
 
 // function createServer instantiates a server instance
@@ -89,165 +153,411 @@ func createServer(config *config.Config) server {
 		contentDir:  config.Options.ContentDir,
 		staticDir:   config.Options.StaticDir,
 		templateDir: config.Options.TemplateDir,
-		staticRoot:  config.Options.GetStaticPath(),
+		staticRoot:  config.Build.GetStaticPath(),
+		current:     &atomic.Pointer[snapshot]{},
 	}
 
 	return s
 }
 
 // function loadViewLayer loads the markup in the content dir
-// and then stores them in the server instance.
+// and then stores them in the server instance, along with the
+// static & content files copied to the build dir.
 //
 // Right now the contents of the file are stored in the struct
 // but this could prove to be less than performant and not scalable.
-//
-// TODO: handle error value
 func (s *server) loadViewLayer() {
-	var err error
-	ServerLogger.Debug("loading views")
+	s.loadViews()
+	s.loadStatic()
+	s.loadContentFiles()
+}
 
+// function build loads & renders the whole site and publishes it
+func (s *server) build() {
 	s.locks.documentLoader.Lock()
 	defer s.locks.documentLoader.Unlock()
 
-	s.views, err = view.NewViews(s.config.Options.ContentDir, s.config.Options.TemplateDir)
+	s.loadViewLayer()
+	if err := s.addRoutes(); err != nil {
+		ServerLogger.Error(err.Error())
+	}
+}
+
+// function loadViews reads every markdown file in the content dir
+func (s *server) loadViews() {
+	var err error
+	ServerLogger.Debug("loading views")
+
+	s.views, err = view.NewViews(s.config.Options.ContentDir, s.config.Options.TemplateDir, view.PreviewConfig(s.config))
 	if err != nil && len(s.views) > 0 {
 		ServerLogger.Warn(err.Error())
 	}
 
-	s.staticPaths, _ = build.CopyStaticFiles(s.config)
+	opts, err := s.config.Markdown.Parse()
+	if err != nil {
+		ServerLogger.Error(err.Error())
+	}
 
-	build.CollectStatic(s.config)
+	s.views = view.WithImages(view.WithOptions(s.views, opts), view.NewImageProcessor(s.config))
 }
 
-func (s *server) reloadHandler() {
-	s.loadViewLayer()
-	s.addRoutes()
-	s.addLoggingMiddleware()
+// function loadStatic copies the static dir & theme to the build dir
+// and processes them (minification & fingerprinting)
+func (s *server) loadStatic() {
+	var err error
+	s.staticPaths, err = build.CollectStatic(s.config)
+	if err != nil {
+		ServerLogger.Warn(err.Error())
+	}
 
-	s.server.Handler = s.handler
+	if err := build.ProcessAssets(s.config); err != nil {
+		ServerLogger.Warn(err.Error())
+	}
 }
 
-// function addRoutes parses a directory of template files and
-// executes them based on html files found in the templates
-// directory (defaults to /templates)
-func (s *server) addRoutes() error {
-	ServerLogger.Debug("registering routes")
+// function loadContentFiles copies the non-markdown files in the
+// content dir to the build dir
+func (s *server) loadContentFiles() {
+	var err error
+	s.contentPaths, err = build.CopyContentFiles(s.config)
+	if err != nil {
+		ServerLogger.Warn(err.Error())
+	}
+}
 
-	mux := http.NewServeMux()
-	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir(s.staticRoot))))
-	ServerLogger.Infof("Serving static files from %v at /assets/", s.staticRoot)
+// function reloadHandler applies the changes recorded by the watcher &
+// publishes a new snapshot. Views of the current snapshot are cloned
+// before they're re-rendered, so that the HTML it serves isn't
+// modified (their Page & Site are shared). Only the affected parts of
+// the site are rebuilt:
+//
+//   - a config change reloads everything, and returns true if the new
+//     config needs a restart of the listener
+//   - added & removed pages (or frontmatter changes) reload every page,
+//     as they affect navigation & listings
+//   - template changes re-render every page
+//   - edits to the body of a page re-render that page and the pages
+//     that list it, see dependents
+//   - static & content files are only copied when they changed
+func (s *server) reloadHandler() bool {
+	s.locks.documentLoader.Lock()
+	defer s.locks.documentLoader.Unlock()
 
-	for _, v := range s.views {
-		if route, err := v.BuildHTMLFileContents(s.config); err != nil {
-			return fmt.Errorf("unable to build file for route %v %w", route, err)
+	p := s.plan(s.takeChanges())
+	if p.config && s.reloadConfig() {
+		return true
+	}
+
+	if p.empty() {
+		return false
+	}
+
+	if !p.full() && len(p.pages) > 0 {
+		pages, ok := s.updatePages(p.pages)
+		if !ok {
+			p.views = true
+		} else if !p.render {
+			if err := s.render(s.dependents(pages)); err != nil {
+				ServerLogger.Error(err.Error())
+			}
 		} else {
-			mux.HandleFunc(route, v.Handler(ServerLogger))
-			ServerLogger.Infof("Registered Route: %v", route)
+			// Every page is rendered below, with the new stats
+			for _, v := range pages {
+				v.UpdateStats()
+			}
 		}
 	}
 
-	s.handler = mux
+	if p.full() {
+		s.loadViews()
+	}
 
-	return nil
-}
+	if p.full() || p.static {
+		s.loadStatic()
+	}
 
-// function watchFiles instantiates a filesystem watcher that
-// responds to the context in the application.
-func (s *server) watchFiles(ctx context.Context, reload chan struct{}) error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		ServerLogger.Errorf("unable to create watcher: %v", err.Error())
+	if p.full() || p.content {
+		s.loadContentFiles()
 	}
 
-	defer watcher.Close()
+	if p.render && !p.full() {
+		for i, v := range s.views {
+			s.views[i] = cloneView(v)
+		}
 
-	if err = watcher.Add(s.contentDir); err != nil {
-		return fmt.Errorf("unable to read content dir %v %w", s.contentDir, err)
+		view.WithListings(s.views)
 	}
 
-	if err = watcher.Add(s.templateDir); err != nil {
-		ServerLogger.Warnf("unable to read template dir %v", s.templateDir)
+	if p.full() || p.render {
+		if err := s.render(s.views); err != nil {
+			ServerLogger.Error(err.Error())
+		}
 	}
 
-	if err = watcher.Add(s.staticDir); err != nil {
-		ServerLogger.Warnf("unable to read static dir %v", s.staticDir)
+	s.publish()
+	return false
+}
+
+// function cloneView copies a view & its markdown, so it can be
+// rendered without changing the HTML served by the current snapshot.
+// Page & Site aren't copied, they're only read while rendering, which
+// happens with the document loader locked.
+func cloneView(v *view.View) *view.View {
+	c := *v
+	m := *v.Markdown
+	c.Markdown = &m
+
+	return &c
+}
+
+// function updatePages re-reads the markdown files in paths. It returns
+// false when one of them isn't a page yet or its frontmatter changed,
+// in which case every page needs to be reloaded.
+func (s *server) updatePages(paths []string) ([]*view.View, bool) {
+	byPath := map[string]int{}
+	for i, v := range s.views {
+		byPath[filepath.Clean(v.Markdown.FilePath)] = i
 	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			ServerLogger.Debug("stopping watcher...")
-			return nil
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return nil
-			}
+	contents := map[int][]byte{}
+	for _, fp := range paths {
+		i, ok := byPath[filepath.Clean(fp)]
+		if !ok {
+			return nil, false
+		}
 
-			ServerLogger.Debugf("Event: %v | Operation: %v ", event.Name, event.Op.String())
-			restart := false
-			switch event.Op {
-			case fsnotify.Create:
-				ServerLogger.Debugf("created file %v", event.Name)
-				restart = true
-				break
-			case fsnotify.Remove:
-				ServerLogger.Debugf("removed file %v", event.Name)
-				restart = true
-				break
-			case fsnotify.Chmod:
-			case fsnotify.Write:
-				ServerLogger.Debugf("modified file %v", event.Name)
-				restart = true
-				break
-			default:
-				ServerLogger.Warnf("unsupported operation %v", event.Op.String())
-			}
+		m, err := md.OpenContentFile(fp)
+		if err != nil || !reflect.DeepEqual(m.Frontmatter, s.views[i].Markdown.Frontmatter) {
+			return nil, false
+		}
 
-			if restart {
-				select {
-				case reload <- struct{}{}:
-				default: // no-op
-				}
+		contents[i] = m.Content
+	}
+
+	updated := []*view.View{}
+	for i, content := range contents {
+		v := cloneView(s.views[i])
+		v.Markdown.Content = content
+		s.views[i] = v
+		updated = append(updated, v)
+		ServerLogger.Debugf("updated %v", v.Markdown.FilePath)
+	}
+
+	return updated, true
+}
+
+// function dependents updates the stats of the edited views and returns
+// them with the views that depend on them. Generated section pages list
+// their children with their summary & reading time, and templates in
+// the template dir can list any page through .Site.Pages, so they're
+// rendered again when the stats change. Frontmatter changes (ex. a new
+// title or weight) reload every page instead, see updatePages.
+func (s *server) dependents(edited []*view.View) []*view.View {
+	listed := map[*view.Page]bool{}
+	changed := false
+	for _, v := range edited {
+		if v.UpdateStats() {
+			changed = true
+			if v.Page.Parent != nil {
+				listed[v.Page.Parent] = true
 			}
-		case err, _ := <-watcher.Errors:
-			return err
 		}
 	}
+
+	views := slices.Clone(edited)
+	if !changed {
+		return views
+	}
+
+	for i, v := range s.views {
+		if slices.Contains(edited, v) || (!listed[v.Page] && !v.CustomLayout()) {
+			continue
+		}
+
+		s.views[i] = cloneView(v)
+		views = append(views, s.views[i])
+	}
+
+	return view.WithListings(views)
+}
+
+// function reloadConfig re-reads the config & applies it. Metadata,
+// themes & menus apply on the next build of the site. A different port
+// or directory returns true, as the listener & watcher need a restart.
+// Invalid configs are reported and the current config is kept.
+func (s *server) reloadConfig() bool {
+	if s.openConfig == nil {
+		return false
+	}
+
+	conf, err := s.openConfig()
+	if err != nil {
+		ServerLogger.Errorf("invalid config, keeping the current config\n%v", err.Error())
+		return false
+	}
+
+	ServerLogger.Info("config changed")
+	return s.applyConfig(conf)
+}
+
+// function applyConfig replaces the config of the server and reports
+// whether the listener & watcher need to be restarted
+func (s *server) applyConfig(conf *config.Config) bool {
+	next := createServer(conf)
+	restart := next.port != s.port ||
+		next.contentDir != s.contentDir ||
+		next.templateDir != s.templateDir ||
+		next.staticDir != s.staticDir ||
+		next.staticRoot != s.staticRoot
+
+	s.config = conf
+	s.port = next.port
+	s.contentDir = next.contentDir
+	s.templateDir = next.templateDir
+	s.staticDir = next.staticDir
+	s.staticRoot = next.staticRoot
+
+	logs.SetLogLevel(ServerLogger, conf.Options.Level)
+	return restart
+}
+
+// function addRoutes renders every view into the build directory
+// (defaults to /dist) and publishes a snapshot with a route for each.
+// Views that fail to render aren't routed.
+func (s *server) addRoutes() error {
+	err := s.render(s.views)
+	s.publish()
+
+	return err
+}
+
+// function render executes the templates of views & writes the pages
+// to the build directory
+func (s *server) render(views []*view.View) error {
+	for _, v := range views {
+		if route, err := v.BuildHTMLFileContents(s.config); err != nil {
+			return fmt.Errorf("unable to build file for route %v %w", route, err)
+		}
+
+		// Only the served page has the banner, not the built file
+		v.HTML = v.PreviewBanner(v.HTML)
+
+		for _, w := range v.Warnings {
+			ServerLogger.Warn(w.Error())
+		}
+	}
+
+	return nil
+}
+
+// function routes creates a mux that serves the rendered views, the
+// static files and the files copied from the content directory
+func (s *server) routes() http.Handler {
+	ServerLogger.Debug("registering routes")
+
+	mux := http.NewServeMux()
+	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir(s.staticRoot))))
+	ServerLogger.Infof("Serving static files from %v at /assets/", s.staticRoot)
+
+	routes := map[string]bool{}
+	for _, v := range s.views {
+		if v.HTML == nil {
+			continue
+		}
+
+		route := v.Route()
+		mux.HandleFunc(route, v.Handler(ServerLogger))
+		routes[route] = true
+		ServerLogger.Infof("Registered Route: %v", route)
+	}
+
+	for _, f := range s.contentPaths {
+		fp, route := f.FileP, "/"+f.Name
+		if routes[route] {
+			ServerLogger.Warnf("%v is shadowed by a page", fp)
+			continue
+		}
+
+		mux.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, fp)
+		})
+	}
+
+	return mux
 }
 
 // function address is a getter for the address of the server
-func (s server) address() string {
+func (s *server) address() string {
 	return fmt.Sprintf(":%v", s.port)
 }
 
+// function listen serves the site until ctx is done. Reload requests
+// rebuild the site, unless the config changed in a way that needs a new
+// listener, in which case the server is shutdown & errRestart returned.
 func (s *server) listen(ctx context.Context, reload chan struct{}) error {
-	s.server = &http.Server{Addr: s.address(), Handler: s.handler}
+	if s.handler == nil {
+		s.addLoggingMiddleware()
+	}
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	srv := &http.Server{Addr: s.address(), Handler: s.handler}
+
+	s.locks.serverStarter.Lock()
+	s.server = srv
+	s.locks.serverStarter.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	restart := false
+	shutdown := func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
-		if err := s.server.Shutdown(shutdownCtx); err != nil {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
 			if err == http.ErrServerClosed {
 				ServerLogger.Info("closing server...")
 			} else {
 				ServerLogger.Errorf("something went wrong %v", err.Error())
 			}
 		}
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdown()
+		case <-done:
+		}
 	}()
 
 	go func() {
-		for range reload {
-			fmt.Print("\033[H\033[2J")
-			ServerLogger.Infof("reloading documents...")
+		for {
+			select {
+			case <-done:
+				return
+			case <-reload:
+				fmt.Print("\033[H\033[2J")
+				ServerLogger.Infof("reloading documents...")
+
+				if s.reloadHandler() {
+					s.locks.serverStarter.Lock()
+					restart = true
+					s.locks.serverStarter.Unlock()
+
+					shutdown()
+					return
+				}
+			}
+		}
+	}()
 
-			time.Sleep(500 * time.Millisecond)
+	if err := srv.ListenAndServe(); err != nil {
+		s.locks.serverStarter.RLock()
+		defer s.locks.serverStarter.RUnlock()
 
-			s.reloadHandler()
+		if err == http.ErrServerClosed && restart {
+			return errRestart
 		}
-	}()
 
-	if err := s.server.ListenAndServe(); err != nil {
 		if err == http.ErrServerClosed {
 			ServerLogger.Info("server closed")
 		}
@@ -258,6 +568,19 @@ func (s *server) listen(ctx context.Context, reload chan struct{}) error {
 	return nil
 }
 
+// function stop gracefully shuts down the current listener
+func (s *server) stop(ctx context.Context) error {
+	s.locks.serverStarter.RLock()
+	srv := s.server
+	s.locks.serverStarter.RUnlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Shutdown(ctx)
+}
+
 // function Run is an ActionFunc for the cli library. It creates a filesystem
 // watcher for the provided directory and a server that handles requests to the
 // provided address. When a change is detected in the filesystem, the server is
@@ -270,23 +593,79 @@ func Run(ctx context.Context, c *cli.Command) error {
 
 	s := createServer(conf)
 	s.createLocks()
-	s.loadViewLayer()
-	s.staticPaths, _ = build.CopyStaticFiles(s.config)
-	s.addRoutes()
+	s.watchConfig(c)
+	s.build()
 	s.addLoggingMiddleware()
 
 	machine := createMachine()
 	reload := make(chan struct{}, 1)
 
 	defer machine.canceller()
-	go s.watchFiles(machine.ctx, reload)
 	go func() {
 		signal.Notify(stopSignal, os.Interrupt, syscall.SIGTERM)
-		<-stopSignal
+		defer signal.Stop(stopSignal)
+
+		select {
+		case <-stopSignal:
+		case <-ctx.Done():
+		case <-machine.ctx.Done():
+		}
+
 		machine.canceller()
 	}()
 
-	s.listen(machine.ctx, reload)
+	for {
+		ctx, cancel := context.WithCancel(machine.ctx)
+		watched := make(chan struct{})
+		go func() {
+			defer close(watched)
+			s.watchFiles(ctx, reload)
+		}()
 
-	return nil
+		err := s.listen(ctx, reload)
+		cancel()
+
+		// The watcher is stopped before the next listener starts one
+		<-watched
+
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			return nil
+		} else if !errors.Is(err, errRestart) {
+			return fmt.Errorf("unable to serve %v %w", s.address(), err)
+		}
+
+		ServerLogger.Infof("restarting server at %v", s.address())
+		s.build()
+	}
+}
+
+// function watchConfig sets up the server to re-read the config file
+// passed to the root command (& the overlay of its --env) when either
+// changes, with the same flags
+func (s *server) watchConfig(c *cli.Command) {
+	p := c.Root().String("file")
+	if p == "" {
+		return
+	}
+
+	env := c.Root().String("env")
+	paths := []string{p}
+	if env != "" {
+		paths = append(paths, config.EnvConfigPath(p, env))
+	}
+
+	for _, fp := range paths {
+		if abs, err := filepath.Abs(fp); err == nil {
+			s.configPaths = append(s.configPaths, abs)
+		}
+	}
+
+	s.openConfig = func() (*config.Config, error) {
+		conf, err := config.OpenEnvConfig(p, env)
+		if err != nil {
+			return conf, err
+		}
+
+		return conf, conf.SetFlags(c)
+	}
 }
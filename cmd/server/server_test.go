@@ -3,8 +3,12 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -14,12 +18,14 @@ import (
 	"github.com/desertthunder/documango/cmd/build"
 	"github.com/desertthunder/documango/internal/config"
 	"github.com/desertthunder/documango/internal/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v3"
 )
 
 func setupConf() (string, string, *config.Config) {
 	root := utils.FindWDRoot()
 	base_path := fmt.Sprintf("%v/example", root)
-	conf := config.OpenConfig(fmt.Sprintf("%v/%v", base_path, "config.toml"))
+	conf, _ := config.OpenConfig(fmt.Sprintf("%v/%v", base_path, "config.toml"))
 	return root, base_path, conf
 }
 
@@ -27,16 +33,85 @@ func mutateConf(conf *config.Config) {
 	root := utils.FindWDRoot()
 	base_path := fmt.Sprintf("%v/example", root)
 
-	conf.Options.BuildDir = fmt.Sprintf("%v/%v", base_path, conf.Options.BuildDir)
+	conf.Build.BuildDir = fmt.Sprintf("%v/%v", base_path, conf.Build.BuildDir)
 	conf.Options.TemplateDir = fmt.Sprintf("%v/%v", base_path, conf.Options.TemplateDir)
 	conf.Options.ContentDir = fmt.Sprintf("%v/%v", base_path, conf.Options.ContentDir)
 	conf.Options.StaticDir = fmt.Sprintf("%v/%v", base_path, conf.Options.StaticDir)
 }
 
-func TestServer(t *testing.T) {
-	wg := sync.WaitGroup{}
+// type syncBuffer is a log output that can be read while the server
+// is writing to it
+type syncBuffer struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sb.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sb.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sb.Reset()
+}
+
+// function freePort finds a port that nothing is listening on, so that
+// tests don't collide with each other or a running server
+func freePort(t *testing.T) int32 {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("unable to find a free port %v", err.Error())
+	}
+
+	defer l.Close()
+	return int32(l.Addr().(*net.TCPAddr).Port)
+}
 
-	sb := strings.Builder{}
+// function siteConf creates a small site in dir & a config for it
+func siteConf(t *testing.T, dir string) *config.Config {
+	files := map[string]string{
+		"content/index.md":         "# Home",
+		"content/guides/setup.md":  "---\ntitle: Setup\n---\n\nSetup",
+		"templates/.gitkeep":       "",
+		"static/styles.custom.css": "body {}",
+	}
+
+	for fp, contents := range files {
+		fp = filepath.Join(dir, fp)
+		os.MkdirAll(filepath.Dir(fp), 0755)
+		if err := os.WriteFile(fp, []byte(contents), 0644); err != nil {
+			t.Fatalf("unable to write %v %v", fp, err.Error())
+		}
+	}
+
+	conf := config.NewDefaultConfig()
+	conf.Options.ContentDir = filepath.Join(dir, "content")
+	conf.Options.TemplateDir = filepath.Join(dir, "templates")
+	conf.Options.StaticDir = filepath.Join(dir, "static")
+	conf.Build.BuildDir = filepath.Join(dir, "dist")
+	conf.Images.CacheDir = filepath.Join(dir, "cache")
+
+	return &conf
+}
+
+// watchTimeout is how long tests wait for the watcher to request a
+// reload, generous so that slow machines don't fail them
+const watchTimeout = 10 * time.Second
+
+func TestServer(t *testing.T) {
+	sb := syncBuffer{}
 	ServerLogger = log.Default()
 	ServerLogger.SetOutput(&sb)
 	build.BuildLogger = ServerLogger
@@ -72,14 +147,14 @@ func TestServer(t *testing.T) {
 
 	t.Run("adds locks to the server", func(t *testing.T) {
 		s := createServer(conf)
-		if s.locks.documentLoader != nil || s.locks.serverStarter != nil {
-			t.Error("neither lock should be defined at this point")
+		if s.locks.documentLoader != nil || s.locks.serverStarter != nil || s.locks.pendingChanges != nil {
+			t.Error("no lock should be defined at this point")
 		}
 
 		s.createLocks()
 
-		if s.locks.documentLoader == nil || s.locks.serverStarter == nil {
-			t.Error("both locks should be defined at this point")
+		if s.locks.documentLoader == nil || s.locks.serverStarter == nil || s.locks.pendingChanges == nil {
+			t.Error("every lock should be defined at this point")
 		}
 	})
 
@@ -110,10 +185,10 @@ func TestServer(t *testing.T) {
 
 		s.loadViewLayer()
 
-		_, err = os.ReadFile(fmt.Sprintf("%v/assets/%v", s.config.Options.BuildDir, jsFile.Name()))
+		_, err = os.ReadFile(fmt.Sprintf("%v/assets/%v", s.config.Build.BuildDir, jsFile.Name()))
 
 		if err != nil {
-			t.Fatalf("unable to find js file %v in %v/assets: %v", jsFile.Name(), s.config.Options.BuildDir, err.Error())
+			t.Fatalf("unable to find js file %v in %v/assets: %v", jsFile.Name(), s.config.Build.BuildDir, err.Error())
 		}
 	})
 
@@ -122,26 +197,48 @@ func TestServer(t *testing.T) {
 		s.createLocks()
 		s.addRoutes()
 		s.addLoggingMiddleware()
+		s.port = freePort(t)
 		p := fmt.Sprintf("http://localhost:%v", s.port)
 		c := &http.Client{}
 
 		t.Run("listen opens a connection to the server address", func(t *testing.T) {
+			wg := sync.WaitGroup{}
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(func() {
+				s.stop(context.Background())
+				cancel()
+				wg.Wait()
+			})
+
 			reload := make(chan struct{}, 1)
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				s.listen(machine.ctx, reload)
+				s.listen(ctx, reload)
 			}()
 
+			// Wait for the listener before sending the request
+			deadline := time.Now().Add(watchTimeout)
+			for {
+				conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", s.port))
+				if err == nil {
+					conn.Close()
+					break
+				}
+
+				if time.Now().After(deadline) {
+					t.Fatalf("the server should be listening %v", err.Error())
+				}
+
+				time.Sleep(50 * time.Millisecond)
+			}
+
 			req, _ := http.NewRequest(http.MethodGet, p, nil)
 
 			_, err := c.Do(req)
 			if err != nil {
 				t.Fatalf("the server should have handled this %v", err.Error())
 			}
-
-			time.Sleep(3 * time.Second)
-			s.server.Shutdown(machine.ctx)
 		})
 
 		req, _ := http.NewRequest(http.MethodGet, p, nil)
@@ -159,8 +256,370 @@ func TestServer(t *testing.T) {
 		}
 	})
 
+	t.Run("config changes apply live or restart the listener", func(t *testing.T) {
+		_, _, conf := setupConf()
+		mutateConf(conf)
+		s := createServer(conf)
+		s.createLocks()
+
+		next := *conf
+		next.Metadata.Name = "Renamed"
+		s.openConfig = func() (*config.Config, error) {
+			c := next
+			return &c, nil
+		}
+
+		if s.reloadConfig() || s.config.Metadata.Name != "Renamed" {
+			t.Errorf("metadata should apply without a restart, got %v", s.config.Metadata.Name)
+		}
+
+		next.Options.Port = conf.Options.Port + 1
+		if !s.reloadConfig() || s.port != next.Options.Port {
+			t.Errorf("a new port should restart the listener, got %v", s.port)
+		}
+
+		s.openConfig = func() (*config.Config, error) { return nil, fmt.Errorf("invalid") }
+		if s.reloadConfig() || s.port != next.Options.Port {
+			t.Errorf("invalid configs should be ignored, got %v", s.port)
+		}
+	})
+
+	t.Run("theme changes apply on reload", func(t *testing.T) {
+		_, _, conf := setupConf()
+		mutateConf(conf)
+		conf.Build.BuildDir = t.TempDir()
+		s := createServer(conf)
+		s.createLocks()
+
+		next := *conf
+		next.Theme.Dark = "rose-pine"
+		s.openConfig = func() (*config.Config, error) {
+			c := next
+			return &c, nil
+		}
+
+		if s.reloadConfig() {
+			t.Fatal("a new theme shouldn't restart the listener")
+		}
+
+		s.loadStatic()
+
+		data, err := os.ReadFile(filepath.Join(s.config.Build.GetStaticPath(), "styles.css"))
+		if err != nil {
+			t.Fatalf("unable to read stylesheet %v", err.Error())
+		}
+
+		if !strings.Contains(string(data), "#191724") {
+			t.Error("the stylesheet should use the rose-pine palette")
+		}
+	})
+
+	t.Run("watchFiles marks config changes", func(t *testing.T) {
+		_, _, conf := setupConf()
+		mutateConf(conf)
+		s := createServer(conf)
+		s.createLocks()
+
+		fp := fmt.Sprintf("%v/config.toml", t.TempDir())
+		os.WriteFile(fp, []byte("[meta]\nname = \"Docs\"\n"), 0644)
+		s.configPaths = []string{fp}
+
+		wg := sync.WaitGroup{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer wg.Wait()
+		defer cancel()
+
+		reload := make(chan struct{}, 1)
+		s.watching = make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watchFiles(ctx, reload)
+		}()
+		<-s.watching
+
+		os.WriteFile(fp, []byte("[meta]\nname = \"Renamed\"\n"), 0644)
+
+		select {
+		case <-reload:
+		case <-time.After(watchTimeout):
+			t.Fatal("a config change should request a reload")
+		}
+
+		if !s.plan(s.takeChanges()).config {
+			t.Error("the config change should be recorded")
+		}
+	})
+
+	t.Run("watchFiles watches nested & new directories", func(t *testing.T) {
+		// A longer debounce keeps the burst of writes below in a single
+		// reload on slow machines
+		defer func(d time.Duration) { Debounce = d }(Debounce)
+		Debounce = 500 * time.Millisecond
+
+		dir := t.TempDir()
+		conf := siteConf(t, dir)
+		s := createServer(conf)
+		s.createLocks()
+
+		wg := sync.WaitGroup{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer wg.Wait()
+		defer cancel()
+
+		reload := make(chan struct{}, 1)
+		s.watching = make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watchFiles(ctx, reload)
+		}()
+		<-s.watching
+
+		nested := filepath.Join(conf.Options.ContentDir, "guides", "advanced")
+		os.Mkdir(nested, 0755)
+		select {
+		case <-reload:
+			s.takeChanges()
+		case <-time.After(watchTimeout):
+			t.Fatal("new directories should request a reload")
+		}
+
+		os.WriteFile(filepath.Join(conf.Options.ContentDir, "guides", "setup.md"), []byte("# Setup\n\nUpdated"), 0644)
+		os.WriteFile(filepath.Join(nested, "tips.md"), []byte("# Tips"), 0644)
+		os.Chmod(filepath.Join(conf.Options.ContentDir, "index.md"), 0600)
+
+		select {
+		case <-reload:
+		case <-time.After(watchTimeout):
+			t.Fatal("changes should request a reload")
+		}
+
+		select {
+		case <-reload:
+			t.Error("a burst of changes should request a single reload")
+		case <-time.After(2 * Debounce):
+		}
+
+		ch := s.takeChanges()
+		for _, fp := range []string{"guides/setup.md", "guides/advanced/tips.md"} {
+			if _, ok := ch[filepath.Join(conf.Options.ContentDir, fp)]; !ok {
+				t.Errorf("%v should have been recorded, got %v", fp, ch)
+			}
+		}
+
+		if _, ok := ch[filepath.Join(conf.Options.ContentDir, "index.md")]; ok {
+			t.Error("permission changes should be ignored")
+		}
+	})
+
+	t.Run("reloads only rebuild what changed", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := siteConf(t, dir)
+		s := createServer(conf)
+		s.createLocks()
+		s.loadViewLayer()
+		if err := s.addRoutes(); err != nil {
+			t.Fatalf("unable to add routes %v", err.Error())
+		}
+
+		setup := filepath.Join(conf.Options.ContentDir, "guides", "setup.md")
+		index := filepath.Join(conf.Build.BuildDir, "index.html")
+		before, _ := os.Stat(index)
+		os.WriteFile(filepath.Join(conf.Options.ContentDir, "guides", "a.png"), []byte("png"), 0644)
+
+		p := s.plan(changes{setup: fsnotify.Write})
+		if p.full() || len(p.pages) != 1 || p.render || p.static {
+			t.Errorf("a page edit should only render that page, got %+v", p)
+		}
+
+		for fp, check := range map[string]func(plan) bool{
+			filepath.Join(conf.Options.ContentDir, "new.md"):          func(p plan) bool { return p.views },
+			filepath.Join(conf.Options.TemplateDir, "base.html"):      func(p plan) bool { return p.render && !p.full() },
+			filepath.Join(conf.Options.StaticDir, "styles.css"):       func(p plan) bool { return p.static && !p.render },
+			filepath.Join(conf.Options.ContentDir, "guides", "a.png"): func(p plan) bool { return p.content && !p.full() },
+		} {
+			if p := s.plan(changes{fp: fsnotify.Create}); !check(p) {
+				t.Errorf("unexpected plan for %v %+v", fp, p)
+			}
+		}
+
+		// Changing the summary & length of a page renders the pages that
+		// list it
+		os.WriteFile(setup, []byte("---\ntitle: Setup\n---\n\nInstall it\n\n<!--more-->\n\nOld body"), 0644)
+		s.record(fsnotify.Event{Name: setup, Op: fsnotify.Write})
+		s.reloadHandler()
+
+		listing, _ := os.ReadFile(filepath.Join(conf.Build.BuildDir, "guides", "index.html"))
+		if !strings.Contains(string(listing), "Install it") {
+			t.Errorf("the section listing should show the new summary %s", listing)
+		}
+
+		if after, _ := os.Stat(index); !after.ModTime().Equal(before.ModTime()) {
+			t.Error("pages that don't list the edited page shouldn't be re-rendered")
+		}
+
+		os.WriteFile(setup, []byte("---\ntitle: Setup\n---\n\nInstall it\n\n<!--more-->\n\nUpdated body"), 0644)
+		s.record(fsnotify.Event{Name: setup, Op: fsnotify.Write})
+		s.reloadHandler()
+
+		html, _ := os.ReadFile(filepath.Join(conf.Build.BuildDir, "guides", "setup.html"))
+		if !strings.Contains(string(html), "Updated body") {
+			t.Errorf("the page should have been re-rendered %s", html)
+		}
+
+		if after, _ := os.Stat(index); !after.ModTime().Equal(before.ModTime()) {
+			t.Error("other pages shouldn't be re-rendered")
+		}
+
+		os.WriteFile(setup, []byte("---\ntitle: Installation\n---\n\nUpdated body"), 0644)
+		if _, ok := s.updatePages([]string{setup}); ok {
+			t.Error("frontmatter changes should reload every page")
+		}
+
+		s.record(fsnotify.Event{Name: setup, Op: fsnotify.Write})
+		s.reloadHandler()
+
+		listing, _ = os.ReadFile(filepath.Join(conf.Build.BuildDir, "guides", "index.html"))
+		if !strings.Contains(string(listing), "Installation") {
+			t.Errorf("the section listing should show the new title %s", listing)
+		}
+
+		// Templates can list any page, so pages with a custom layout are
+		// rendered when the stats of a page change
+		layout := filepath.Join(conf.Options.TemplateDir, "base.html")
+		os.WriteFile(layout, []byte(`{{ range .Site.Pages }}{{ .Title }}: {{ .Summary }}{{ end }}`), 0644)
+		s.record(fsnotify.Event{Name: layout, Op: fsnotify.Create})
+		s.reloadHandler()
+
+		os.WriteFile(setup, []byte("---\ntitle: Installation\n---\n\nRewritten"), 0644)
+		s.record(fsnotify.Event{Name: setup, Op: fsnotify.Write})
+		s.reloadHandler()
+
+		if html, _ := os.ReadFile(index); !strings.Contains(string(html), "Installation: Rewritten") {
+			t.Errorf("pages with a custom layout should be re-rendered %s", html)
+		}
+	})
+
+	t.Run("requests see a consistent site during concurrent reloads", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := siteConf(t, dir)
+		s := createServer(conf)
+		s.createLocks()
+		s.build()
+		s.addLoggingMiddleware()
+
+		ts := httptest.NewServer(s.handler)
+		defer ts.Close()
+
+		previous := s.current.Load()
+		setup := filepath.Join(conf.Options.ContentDir, "guides", "setup.md")
+
+		stop := make(chan struct{})
+		errs := make(chan error, 8)
+		clients := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			clients.Add(1)
+			go func() {
+				defer clients.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+
+					for _, route := range []string{"/", "/guides/setup"} {
+						res, err := http.Get(ts.URL + route)
+						if err != nil {
+							errs <- err
+							return
+						}
+
+						body, _ := io.ReadAll(res.Body)
+						res.Body.Close()
+						if res.StatusCode != http.StatusOK || !strings.Contains(string(body), "</html>") {
+							errs <- fmt.Errorf("%v: got %v %s", route, res.StatusCode, body)
+							return
+						}
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < 10; i++ {
+			os.WriteFile(setup, []byte(fmt.Sprintf("---\ntitle: Setup\n---\n\nRevision %v", i)), 0644)
+			s.record(fsnotify.Event{Name: setup, Op: fsnotify.Write})
+			if i%3 == 0 {
+				s.record(fsnotify.Event{Name: filepath.Join(conf.Options.TemplateDir, "base.html"), Op: fsnotify.Create})
+			}
+
+			if i%5 == 0 {
+				s.record(fsnotify.Event{Name: filepath.Join(conf.Options.ContentDir, "new.md"), Op: fsnotify.Create})
+			}
+
+			s.reloadHandler()
+		}
+
+		close(stop)
+		clients.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+
+		for _, v := range previous.views {
+			if v.HTML == nil || strings.Contains(string(v.HTML), "Revision") {
+				t.Errorf("published snapshots should not be modified by later rebuilds %v", v.Route())
+			}
+		}
+
+		res, err := http.Get(ts.URL + "/guides/setup")
+		if err != nil {
+			t.Fatalf("unable to get page %v", err.Error())
+		}
+
+		defer res.Body.Close()
+		if body, _ := io.ReadAll(res.Body); !strings.Contains(string(body), "Revision 9") {
+			t.Errorf("the latest snapshot should be served %s", body)
+		}
+	})
+
+	t.Run("previewed pages are served with a banner", func(t *testing.T) {
+		dir := t.TempDir()
+		conf := siteConf(t, dir)
+		conf.Build.Drafts = true
+		os.WriteFile(filepath.Join(conf.Options.ContentDir, "wip.md"), []byte("---\ntitle: WIP\ndraft: true\n---\n\nSoon"), 0644)
+
+		s := createServer(conf)
+		s.createLocks()
+		s.build()
+		s.addLoggingMiddleware()
+
+		ts := httptest.NewServer(s.handler)
+		defer ts.Close()
+
+		for route, banner := range map[string]bool{"/wip": true, "/": false} {
+			res, err := http.Get(ts.URL + route)
+			if err != nil {
+				t.Fatalf("unable to get %v %v", route, err.Error())
+			}
+
+			body, _ := io.ReadAll(res.Body)
+			res.Body.Close()
+			if strings.Contains(string(body), "preview-banner") != banner {
+				t.Errorf("%v should have a banner: %v %s", route, banner, body)
+			}
+		}
+
+		built, _ := os.ReadFile(filepath.Join(conf.Build.BuildDir, "wip.html"))
+		if len(built) == 0 || strings.Contains(string(built), "preview-banner") {
+			t.Errorf("the built page shouldn't have the banner %s", built)
+		}
+	})
+
 	t.Run("Run Command", func(t *testing.T) {
-		wg.Wait()
+		wg := sync.WaitGroup{}
 		sb := strings.Builder{}
 		ServerLogger = log.Default()
 		ServerLogger.SetOutput(&sb)
@@ -182,6 +641,7 @@ func TestServer(t *testing.T) {
 		time.Sleep(2 * time.Second)
 
 		cancelFunc()
+		wg.Wait()
 
 		if err != nil {
 			t.Errorf("execution failed %v", err.Error())
@@ -200,6 +660,9 @@ func TestServer(t *testing.T) {
 		ctx := context.TODO()
 		ctx = context.WithValue(ctx, config.LoggerKey, ServerLogger)
 		ctx, cancelFunc := context.WithCancel(ctx)
+		wg := sync.WaitGroup{}
+		defer wg.Wait()
+		defer cancelFunc()
 
 		wg.Add(1)
 		s := createServer(conf)
@@ -207,10 +670,11 @@ func TestServer(t *testing.T) {
 		s.addRoutes()
 		s.addLoggingMiddleware()
 
+		// watchFiles requests reloads on this channel, listen logs them
+		reload := make(chan struct{}, 1)
 		go func() {
-			<-ctx.Done()
 			defer wg.Done()
-			s.watchFiles(ctx, make(chan struct{}))
+			s.watchFiles(ctx, reload)
 		}()
 		tmp_path := fmt.Sprintf("%v/test.md", conf.Options.ContentDir)
 
@@ -234,10 +698,73 @@ func TestServer(t *testing.T) {
 		cancelFunc()
 		os.Remove(tmp_path)
 
-		out := sb.String()
+		if len(reload) == 0 {
+			t.Error("watchFiles should have requested a reload")
+		}
+	})
+
+	t.Run("Run reports listen errors", func(t *testing.T) {
+		l, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatalf("unable to listen %v", err.Error())
+		}
+
+		defer l.Close()
+
+		conf := siteConf(t, t.TempDir())
+		conf.Options.Port = int32(l.Addr().(*net.TCPAddr).Port)
+		ctx := context.WithValue(context.Background(), config.ConfKey, conf)
+		ctx = context.WithValue(ctx, config.LoggerKey, ServerLogger)
+
+		err = Run(ctx, &cli.Command{})
+		if err == nil || !strings.Contains(err.Error(), "address already in use") {
+			t.Errorf("the listen error should be returned, got %v", err)
+		}
+	})
 
-		if !strings.Contains(out, "reload") {
-			t.Errorf("watchFiles should have logged a reload event %v", out)
+	t.Run("Run Command reloads on file changes", func(t *testing.T) {
+		sb.Reset()
+		_, _, conf := setupConf()
+		mutateConf(conf)
+		conf.Options.Port = freePort(t)
+		ctx := context.TODO()
+		ctx = context.WithValue(ctx, config.ConfKey, conf)
+		ctx = context.WithValue(ctx, config.LoggerKey, ServerLogger)
+		ctx, cancelFunc := context.WithCancel(ctx)
+		defer cancelFunc()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- ServerCommand.Run(ctx, []string{})
+		}()
+
+		time.Sleep(2 * time.Second)
+
+		tmp_path := fmt.Sprintf("%v/test.md", conf.Options.ContentDir)
+		if err := os.WriteFile(tmp_path, []byte("# Test"), 0644); err != nil {
+			t.Fatalf("unable to write to file %v", err.Error())
+		}
+
+		defer os.Remove(tmp_path)
+
+		deadline := time.Now().Add(watchTimeout)
+		for time.Now().Before(deadline) && !strings.Contains(sb.String(), "reload") {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		if out := sb.String(); !strings.Contains(out, "reload") {
+			t.Errorf("the server should have logged a reload event %v", out)
+		}
+
+		cancelFunc()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("execution failed %v", err.Error())
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("the server should stop when its context is cancelled")
 		}
 	})
 }
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/documango/internal/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Debounce is how long the watcher waits for the filesystem to settle
+// before requesting a reload. Editors & git checkouts write files in
+// bursts, which are coalesced into a single rebuild.
+var Debounce = 100 * time.Millisecond
+
+// type changes is the set of paths (with their operations) changed
+// since the last rebuild
+type changes map[string]fsnotify.Op
+
+// type plan is the work needed to apply a set of changes, from the
+// cheapest (re-rendering a few pages) to a full reload of the site
+type plan struct {
+	// config is set when the config file changed, which reloads
+	// everything
+	config bool
+	// views is set when pages were added, removed or their frontmatter
+	// may have changed, which affects navigation & listings
+	views bool
+	// pages are markdown files whose contents changed
+	pages []string
+	// render re-renders every page, ex. when a template changed
+	render bool
+	// static recopies the static directory
+	static bool
+	// content recopies non-markdown files from the content directory
+	content bool
+}
+
+// function full reports whether the plan reloads the whole site
+func (p plan) full() bool {
+	return p.config || p.views
+}
+
+// type sourceDirs are the directories a watcher watches, read from
+// the server when it starts
+type sourceDirs struct {
+	content   string
+	templates string
+	static    string
+	build     string
+}
+
+// function sourceDirs reads the directories to watch from the server.
+// A config change that moves them restarts the watcher.
+func (s *server) sourceDirs() sourceDirs {
+	s.locks.documentLoader.RLock()
+	defer s.locks.documentLoader.RUnlock()
+
+	return sourceDirs{
+		content:   s.contentDir,
+		templates: s.templateDir,
+		static:    s.staticDir,
+		build:     s.config.Build.BuildDir,
+	}
+}
+
+// function contains reports whether dir (an absolute path) is the
+// content, template or static dir
+func (d sourceDirs) contains(dir string) bool {
+	for _, src := range []string{d.content, d.templates, d.static} {
+		if abs, err := filepath.Abs(src); err == nil && abs == dir {
+			return true
+		}
+	}
+
+	return false
+}
+
+// function skip reports whether changes in dir should be ignored, like
+// the build output (when it's inside a watched directory) and hidden
+// directories like .git
+func (d sourceDirs) skip(dir string) bool {
+	if name := filepath.Base(dir); strings.HasPrefix(name, ".") && name != "." && name != ".." {
+		return true
+	}
+
+	return within(d.build, dir)
+}
+
+// function empty reports whether there's nothing to rebuild
+func (p plan) empty() bool {
+	return !p.config && !p.views && !p.render && !p.static && !p.content && len(p.pages) == 0
+}
+
+// function watchFiles instantiates a filesystem watcher that
+// responds to the context in the application. The content, template
+// & static directories are watched recursively, including directories
+// created after the watcher starts. Changes are recorded & a reload is
+// requested once events stop arriving for the Debounce duration.
+func (s *server) watchFiles(ctx context.Context, reload chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		ServerLogger.Errorf("unable to create watcher: %v", err.Error())
+		return err
+	}
+
+	defer watcher.Close()
+
+	src := s.sourceDirs()
+	if err = src.watch(watcher, src.content); err != nil {
+		return fmt.Errorf("unable to read content dir %v %w", src.content, err)
+	}
+
+	if err = src.watch(watcher, src.templates); err != nil {
+		ServerLogger.Warnf("unable to read template dir %v", src.templates)
+	}
+
+	if err = src.watch(watcher, src.static); err != nil {
+		ServerLogger.Warnf("unable to read static dir %v", src.static)
+	}
+
+	// Editors often save by replacing the file, so the directory of the
+	// config file is watched rather than the file itself
+	dirs := map[string]bool{}
+	for _, p := range s.configPaths {
+		dir := filepath.Dir(p)
+		if dirs[dir] || src.contains(dir) {
+			continue
+		}
+
+		dirs[dir] = true
+		if err = watcher.Add(dir); err != nil {
+			ServerLogger.Warnf("unable to watch config dir %v", dir)
+		}
+	}
+
+	if s.watching != nil {
+		close(s.watching)
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			ServerLogger.Debug("stopping watcher...")
+			return nil
+		case <-settled:
+			settled = nil
+			select {
+			case reload <- struct{}{}:
+			default: // a reload is already pending
+			}
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			ServerLogger.Debugf("Event: %v | Operation: %v ", event.Name, event.Op.String())
+
+			// Other files next to the config file
+			if dirs[filepath.Dir(event.Name)] && !s.isConfigFile(event.Name) {
+				continue
+			}
+
+			// Permission & timestamp changes (ex. from indexers or
+			// antivirus software) don't change the site
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			switch {
+			case event.Has(fsnotify.Create):
+				ServerLogger.Debugf("created file %v", event.Name)
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := src.watch(watcher, event.Name); err != nil {
+						ServerLogger.Warnf("unable to watch %v %v", event.Name, err.Error())
+					}
+				}
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				// Watches on removed directories are dropped by fsnotify
+				ServerLogger.Debugf("removed file %v", event.Name)
+			case event.Has(fsnotify.Write):
+				ServerLogger.Debugf("modified file %v", event.Name)
+			}
+
+			s.record(event)
+			settled = time.After(Debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+// function watch adds dir and its subdirectories to the watcher,
+// skipping the build directory & hidden directories
+func (d sourceDirs) watch(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(fp string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			return nil
+		}
+
+		if fp != dir && d.skip(fp) {
+			return filepath.SkipDir
+		}
+
+		return w.Add(fp)
+	})
+}
+
+// function record adds an event to the pending changes
+func (s *server) record(event fsnotify.Event) {
+	s.locks.pendingChanges.Lock()
+	defer s.locks.pendingChanges.Unlock()
+
+	if s.pending == nil {
+		s.pending = changes{}
+	}
+
+	s.pending[filepath.Clean(event.Name)] |= event.Op &^ fsnotify.Chmod
+}
+
+// function takeChanges returns & clears the pending changes
+func (s *server) takeChanges() changes {
+	s.locks.pendingChanges.Lock()
+	defer s.locks.pendingChanges.Unlock()
+
+	ch := s.pending
+	s.pending = nil
+	return ch
+}
+
+// function plan works out what has to be rebuilt for a set of changes.
+// The template directory is checked before the content directory, as
+// it may live inside it.
+func (s *server) plan(ch changes) plan {
+	p := plan{}
+	for fp, op := range ch {
+		switch {
+		case s.isConfigFile(fp):
+			p.config = true
+		case within(s.templateDir, fp):
+			p.render = true
+		case within(s.staticDir, fp):
+			p.static = true
+			// Pages reference fingerprinted assets by their hashed name
+			if s.config != nil && s.config.Build.Fingerprint {
+				p.render = true
+			}
+		case within(s.contentDir, fp):
+			if utils.IsNotMarkdown(fp) {
+				p.content = true
+				// Added & removed directories may contain pages
+				if info, err := os.Stat(fp); op != fsnotify.Write && (err != nil || info.IsDir()) {
+					p.views = true
+				}
+			} else if op == fsnotify.Write {
+				p.pages = append(p.pages, fp)
+			} else {
+				p.views = true
+			}
+		}
+	}
+
+	sort.Strings(p.pages)
+	return p
+}
+
+// function isConfigFile reports whether fp is the config file or the
+// overlay of the selected environment
+func (s *server) isConfigFile(fp string) bool {
+	abs, err := filepath.Abs(fp)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range s.configPaths {
+		if p == abs {
+			return true
+		}
+	}
+
+	return false
+}
+
+// function within reports whether fp is dir or inside of it
+func within(dir, fp string) bool {
+	if dir == "" {
+		return false
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(fp))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
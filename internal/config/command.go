@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v3"
+)
+
+var ConfigCommand = &cli.Command{
+	Name:  "config",
+	Usage: "print the effective config & where each value comes from",
+	Description: strings.Join(
+		[]string{
+			"values are layered as defaults < config.toml < the --env overlays <",
+			"DOCUMANGO_* environment variables < flags. each value is followed by",
+			"the layer that set it.",
+		},
+		"\n",
+	),
+	Flags:  BuildFlags(true),
+	Before: ApplyFlags,
+	Action: func(ctx context.Context, c *cli.Command) error {
+		conf, ok := ctx.Value(ConfKey).(*Config)
+		if !ok {
+			return fmt.Errorf("config not found in context")
+		}
+
+		return conf.Write(c.Root().Writer)
+	},
+}
+
+// function Write prints the config as TOML, commenting each value with
+// its source
+func (c *Config) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	table := ""
+	if c.Env != "" {
+		fmt.Fprintf(w, "# env: %v\n\n", c.Env)
+	}
+
+	for _, f := range c.fields() {
+		source := c.Sources[f.key]
+		if source == "" {
+			source = SourceDefault
+		}
+
+		name, key, found := strings.Cut(f.key, ".")
+		if !found {
+			// Tables of their own, like [[menu.main]]
+			tw.Flush()
+			fmt.Fprintf(w, "\n# %v: %v\n", f.key, source)
+			enc := toml.NewEncoder(w)
+			enc.Indent = ""
+			if err := enc.Encode(map[string]any{f.key: f.value.Interface()}); err != nil {
+				return fmt.Errorf("unable to encode %v %w", f.key, err)
+			}
+
+			continue
+		}
+
+		if name != table {
+			if table != "" {
+				fmt.Fprintln(tw)
+			}
+
+			fmt.Fprintf(tw, "[%v]\n", name)
+			table = name
+		}
+
+		v := f.value
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			v = reflect.MakeSlice(v.Type(), 0, 0)
+		}
+
+		value, err := formatValue(v.Interface())
+		if err != nil {
+			return fmt.Errorf("unable to encode %v %w", f.key, err)
+		}
+
+		fmt.Fprintf(tw, "%v = %v\t# %v\n", key, value, source)
+	}
+
+	return tw.Flush()
+}
+
+// function formatValue encodes v as a TOML value
+func formatValue(v any) (string, error) {
+	b := bytes.Buffer{}
+	if err := toml.NewEncoder(&b).Encode(map[string]any{"v": v}); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(b.String(), "v = ")), nil
+}
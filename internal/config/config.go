@@ -5,12 +5,16 @@ package config
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/charmbracelet/log"
 	"github.com/desertthunder/documango/internal/logs"
+	"github.com/desertthunder/documango/internal/md"
 	"github.com/desertthunder/documango/internal/utils"
 	"github.com/urfave/cli/v3"
 )
@@ -24,9 +28,22 @@ var DefaultConfigFile []byte
 var logger = logs.CreateConsoleLogger("[documango 🥭]")
 
 type Config struct {
-	Metadata Meta       `toml:"meta"`
-	Theme    Theme      `toml:"theme"`
-	Options  DevOptions `toml:"dev"`
+	Metadata Meta                  `toml:"meta"`
+	Theme    Theme                 `toml:"theme"`
+	Options  DevOptions            `toml:"dev"`
+	Menus    map[string][]MenuItem `toml:"menu"`
+	Check    CheckOptions          `toml:"check"`
+	Images   ImageOptions          `toml:"images"`
+	Build    BuildOptions          `toml:"build"`
+	Markdown MarkdownOptions       `toml:"markdown"`
+	// Envs are [env.{name}] tables, overlaid on the rest of the file
+	// when the environment is selected
+	Envs map[string]toml.Primitive `toml:"env"`
+	// Env is the selected environment, ex. production
+	Env string `toml:"-"`
+	// Sources records where each value came from, by dotted key (ex.
+	// dev.port)
+	Sources map[string]Source `toml:"-"`
 }
 
 type Meta struct {
@@ -41,12 +58,80 @@ type Theme struct {
 	Dark  string `toml:"dark"`
 }
 
+// type MenuItem is an entry in a [[menu.{name}]] array. Entries
+// are sorted by weight, lowest first, and unweighted entries last.
+type MenuItem struct {
+	Name     string `toml:"name"`
+	URL      string `toml:"url"`
+	Weight   int    `toml:"weight"`
+	External bool   `toml:"external"`
+}
+
+// type CheckOptions configures the link checker. External links are
+// skipped unless an allowlist of url prefixes or hosts is provided, in
+// which case links outside of it are reported.
+type CheckOptions struct {
+	Allow []string `toml:"allow"`
+}
+
+// type BuildOptions configures where & how the site is built.
+// Fingerprinting copies each asset to a name with a hash of its contents
+// and records it in assets/manifest.json for the asset template func.
+// Minify minifies pages, stylesheets & scripts.
+type BuildOptions struct {
+	BuildDir string `toml:"build_dir"`
+	// Exclude lists .gitignore style patterns for static & content
+	// files that shouldn't be copied to the build directory
+	Exclude []string `toml:"exclude"`
+	// Strict turns warnings (ex. broken links) into errors
+	Strict      bool `toml:"strict"`
+	Fingerprint bool `toml:"fingerprint"`
+	Minify      bool `toml:"minify"`
+	// Drafts & Future build pages that aren't published yet: drafts and
+	// pages with a publishDate in the future
+	Drafts bool `toml:"drafts"`
+	Future bool `toml:"future"`
+}
+
+// type ImageOptions configures the processing of images referenced in
+// markdown. A resized variant is generated for each width smaller than
+// the original. Encoded variants are cached in CacheDir between builds.
+type ImageOptions struct {
+	Widths   []int  `toml:"widths"`
+	Quality  int    `toml:"quality"`
+	WebP     bool   `toml:"webp"`
+	Sizes    string `toml:"sizes"`
+	CacheDir string `toml:"cache_dir"`
+}
+
+// type MarkdownOptions configures how markdown is rendered. Math
+// includes the KaTeX assets on every page, rather than only on pages
+// with math: true in their frontmatter. Anchors adds "¶" permalinks to
+// h2-h4 headings. Extensions & Flags name the gomarkdown parser
+// extensions & renderer flags, see md.ParseOptions.
+type MarkdownOptions struct {
+	Math       bool     `toml:"math"`
+	Anchors    bool     `toml:"anchors"`
+	Extensions []string `toml:"extensions"`
+	Flags      []string `toml:"flags"`
+}
+
+// function Parse reads the extensions & flags (see md.ParseOptions)
+// into the options markdown is rendered with
+func (m MarkdownOptions) Parse() (md.Options, error) {
+	opts, err := md.ParseOptions(m.Extensions, m.Flags)
+	opts.Anchors = m.Anchors
+
+	return opts, err
+}
+
+// type DevOptions holds the source directories of the site & the
+// options of the development server
 type DevOptions struct {
 	Port        int32  `toml:"port"`
 	StaticDir   string `toml:"static_dir"`
 	TemplateDir string `toml:"template_dir"`
 	ContentDir  string `toml:"content_dir"`
-	BuildDir    string `toml:"build_dir"`
 	Level       string `toml:"level"`
 }
 
@@ -77,6 +162,28 @@ func BuildFlags(show bool) []cli.Flag {
 			Value:       c.Options.StaticDir,
 			Hidden:      show,
 		},
+		&cli.StringFlag{
+			Name:        "out",
+			Aliases:     []string{"o", "dist"},
+			Required:    false,
+			DefaultText: c.Build.BuildDir,
+			Value:       c.Build.BuildDir,
+			Hidden:      show,
+		},
+	}
+}
+
+// function PreviewFlags are the flags that build unpublished pages
+func PreviewFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "drafts",
+			Usage: "build pages marked as drafts",
+		},
+		&cli.BoolFlag{
+			Name:  "future",
+			Usage: "build pages with a publishDate in the future",
+		},
 	}
 }
 
@@ -90,6 +197,11 @@ func MergeFlags(flag cli.Flag, show bool) []cli.Flag {
 func NewDefaultConfig() Config {
 	c := Config{}
 	toml.Unmarshal([]byte(DefaultConfigFile), &c)
+	c.Sources = map[string]Source{}
+	for _, f := range c.fields() {
+		c.Sources[f.key] = SourceDefault
+	}
+
 	return c
 }
 
@@ -97,22 +209,128 @@ func NewDefaultConfig() Config {
 // which options they have available.
 // func ListThemes() {}
 
-func OpenConfig(p string) *Config {
+// moved maps keys that moved between tables to their new location, so
+// that they're reported with it
+var moved = map[string]string{
+	"dev.build_dir": "build.build_dir",
+}
+
+// function OpenConfig layers the config file at p over the defaults
+// and DOCUMANGO_* environment variables over both. Flags are applied
+// by each command with ApplyFlags.
+//
+// Every problem found (unreadable files, syntax errors, unknown keys,
+// type mismatches & invalid values) is returned, joined, alongside the
+// config. Config files are optional, so a missing file isn't an error.
+func OpenConfig(p string) (*Config, error) {
+	return OpenEnvConfig(p, "")
+}
+
+// function OpenEnvConfig opens the config file at p with the overlays
+// of the environment env: the [env.{env}] table in p, then the file
+// config.{env}.toml next to p. Either (or both) must exist.
+func OpenEnvConfig(p, env string) (*Config, error) {
 	c := NewDefaultConfig()
+	c.Env = env
+
+	meta, f, errs := c.decodeFile(p)
+	errs = append(errs, c.decodeEnvTables(p, meta)...)
+	errs = append(errs, c.unknownKeys(p, f, meta)...)
+
+	if env != "" {
+		overlay := EnvConfigPath(p, env)
+		meta, f, overlayErrs := c.decodeFile(overlay)
+		errs = append(errs, overlayErrs...)
+		errs = append(errs, c.unknownKeys(overlay, f, meta)...)
+
+		if _, ok := c.Envs[env]; !ok && f == "" && len(overlayErrs) == 0 {
+			errs = append(errs, fmt.Errorf("unknown environment %v: add an [env.%v] table to %v or create %v", env, env, p, overlay))
+		}
+	}
+
+	if err := c.ApplyEnv(os.Environ()); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return &c, errors.Join(errs...)
+}
+
+// function EnvConfigPath is the overlay file for env next to the config
+// file at p, ex. config.production.toml
+func EnvConfigPath(p, env string) string {
+	ext := filepath.Ext(p)
+	return fmt.Sprintf("%v.%v%v", strings.TrimSuffix(p, ext), env, ext)
+}
+
+// function decodeFile decodes the config file at p over c and returns
+// its metadata & contents, which are empty if the file doesn't exist
+func (c *Config) decodeFile(p string) (toml.MetaData, string, []error) {
 	f, err := utils.OpenFileSafe(p)
-	if err != nil && !strings.Contains(p, "config.toml") {
-		return &c
+	if os.IsNotExist(err) {
+		return toml.MetaData{}, "", []error{}
+	} else if err != nil {
+		return toml.MetaData{}, "", []error{fmt.Errorf("unable to read config file %w", err)}
 	}
 
-	if err := toml.Unmarshal([]byte(f), &c); err != nil {
-		return &c
+	meta, err := toml.Decode(f, c)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			return meta, "", []error{fmt.Errorf("%v: %v", p, perr.ErrorWithPosition())}
+		}
+
+		// Type mismatches include the line & key, ex. toml: line 3
+		// (last key "dev.port"): incompatible types
+		return meta, "", []error{fmt.Errorf("%v: %w", p, err)}
 	}
 
-	return &c
+	c.setSources(meta, Source(p))
+
+	return meta, f, []error{}
+}
+
+// function decodeEnvTables overlays the table of the selected
+// environment. Every table is decoded, so that mistakes in any of them
+// are reported.
+func (c *Config) decodeEnvTables(p string, meta toml.MetaData) []error {
+	errs := []error{}
+	for name, table := range c.Envs {
+		scratch := NewDefaultConfig()
+		if err := meta.PrimitiveDecode(table, &scratch); err != nil {
+			errs = append(errs, fmt.Errorf("%v: [env.%v] %w", p, name, err))
+			continue
+		}
+
+		if name != c.Env {
+			continue
+		}
+
+		meta.PrimitiveDecode(table, c)
+		c.setSources(meta, Source(fmt.Sprintf("%v [env.%v]", p, name)), "env", name)
+	}
+
+	return errs
+}
+
+// function setSources records source for the values defined in meta,
+// under the table at prefix
+func (c *Config) setSources(meta toml.MetaData, source Source, prefix ...string) {
+	for _, field := range c.fields() {
+		key := append(append([]string{}, prefix...), strings.Split(field.key, ".")...)
+		if meta.IsDefined(key...) {
+			c.Sources[field.key] = source
+		}
+	}
 }
 
-func (d DevOptions) GetStaticPath() string {
-	return fmt.Sprintf("./%v/assets", d.BuildDir)
+// function GetStaticPath is the directory static files are collected
+// into, {build_dir}/assets
+func (d BuildOptions) GetStaticPath() string {
+	return filepath.Join(d.BuildDir, "assets")
 }
 
 func (c Config) UpdateLogLevel(l *log.Logger) {
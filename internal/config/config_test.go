@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/documango/internal/md"
+	"github.com/gomarkdown/markdown/html"
+)
+
+func TestConfig(t *testing.T) {
+	writeConfig := func(t *testing.T, contents string) string {
+		fp := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(fp, []byte(contents), 0644); err != nil {
+			t.Fatalf("unable to write config %v", err.Error())
+		}
+
+		return fp
+	}
+
+	t.Run("values record the layer that set them", func(t *testing.T) {
+		fp := writeConfig(t, "[dev]\nport = 8080\n\n[build]\nminify = true\n")
+		t.Setenv("DOCUMANGO_BUILD_MINIFY", "false")
+		t.Setenv("DOCUMANGO_IMAGES_WIDTHS", "320, 640")
+
+		c, err := OpenConfig(fp)
+		if err != nil {
+			t.Fatalf("config should be valid %v", err.Error())
+		}
+		if c.Options.Port != 8080 || c.Sources["dev.port"] != Source(fp) {
+			t.Errorf("port should come from the file, got %v (%v)", c.Options.Port, c.Sources["dev.port"])
+		}
+
+		if c.Build.Minify || c.Sources["build.minify"] != "env DOCUMANGO_BUILD_MINIFY" {
+			t.Errorf("minify should come from the environment, got %v (%v)", c.Build.Minify, c.Sources["build.minify"])
+		}
+
+		if len(c.Images.Widths) != 2 || c.Images.Widths[1] != 640 {
+			t.Errorf("lists should be comma separated, got %v", c.Images.Widths)
+		}
+
+		if c.Sources["dev.level"] != SourceDefault {
+			t.Errorf("got %v, want default", c.Sources["dev.level"])
+		}
+	})
+
+	t.Run("keys moved from [dev] to [build] are reported with their new location", func(t *testing.T) {
+		_, err := OpenConfig(writeConfig(t, "[dev]\nbuild_dir = \"out\"\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2: unknown key dev.build_dir, it moved to build.build_dir") {
+			t.Errorf("dev.build_dir should be reported, got %v", err)
+		}
+	})
+
+	t.Run("moved keys are reported when the new key is also set", func(t *testing.T) {
+		c, err := OpenConfig(writeConfig(t, "[dev]\nbuild_dir = \"out\"\n\n[build]\nbuild_dir = \"site\"\n\n[env.ci.dev]\nbuild_dir = \"ci\"\n"))
+		if err == nil {
+			t.Fatal("dev.build_dir should be reported")
+		}
+
+		for _, want := range []string{"unknown key dev.build_dir, it moved to build.build_dir", "unknown key env.ci.dev.build_dir, it moved to env.ci.build.build_dir"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%v should contain %v", err.Error(), want)
+			}
+		}
+
+		if c.Build.BuildDir != "site" {
+			t.Errorf("build.build_dir should be used, got %v", c.Build.BuildDir)
+		}
+	})
+
+	t.Run("Set rejects unknown keys & invalid values", func(t *testing.T) {
+		c := NewDefaultConfig()
+		if err := c.Set("dev.nope", "1", SourceDefault); err == nil {
+			t.Error("unknown keys should be an error")
+		}
+
+		if err := c.Set("dev.port", "abc", SourceDefault); err == nil {
+			t.Error("invalid numbers should be an error")
+		}
+
+		if err := c.Set("menu", "x", SourceDefault); err == nil {
+			t.Error("tables can't be set from a string")
+		}
+	})
+
+	t.Run("unknown keys are reported with their line & a suggestion", func(t *testing.T) {
+		_, err := OpenConfig(writeConfig(t, "[dev]\nport = 4242\nstatc_dir = \"assets\"\n\n[mnu]\nname = \"x\"\n"))
+		if err == nil {
+			t.Fatal("unknown keys should be an error")
+		}
+
+		for _, want := range []string{"line 3: unknown key dev.statc_dir, did you mean dev.static_dir?", "line 5: unknown key mnu"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%v should contain %v", err.Error(), want)
+			}
+		}
+
+		if strings.Contains(err.Error(), "mnu.name") {
+			t.Error("keys of unknown tables should not be reported separately")
+		}
+	})
+
+	t.Run("syntax errors & type mismatches have line numbers", func(t *testing.T) {
+		_, err := OpenConfig(writeConfig(t, "[dev]\n\nport = \"4242\"\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "dev.port") {
+			t.Errorf("type mismatches should name the line & key, got %v", err)
+		}
+
+		_, err = OpenConfig(writeConfig(t, "[dev\nport = 4242\n"))
+		if err == nil || !strings.Contains(err.Error(), "At line 2") {
+			t.Errorf("syntax errors should name the line, got %v", err)
+		}
+	})
+
+	t.Run("invalid values are reported with their source", func(t *testing.T) {
+		fp := writeConfig(t, "[dev]\nport = 70000\nlevel = \"loud\"\n\n[theme]\ndark = \"nope\"\n\n[markdown]\nflags = [\"-href_target_blank\", \"blink\"]\n")
+		t.Setenv("DOCUMANGO_IMAGES_QUALITY", "0")
+
+		_, err := OpenConfig(fp)
+		if err == nil {
+			t.Fatal("invalid values should be an error")
+		}
+
+		for _, want := range []string{
+			"dev.port = 70000 (from " + fp + "): must be between 1 and 65535",
+			`dev.level = "loud"`,
+			`theme.dark = "nope"`, "tokyo-city-dark",
+			"images.quality = 0 (from env DOCUMANGO_IMAGES_QUALITY)",
+			"markdown.flags", "unknown renderer flag blink",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%v should contain %v", err.Error(), want)
+			}
+		}
+	})
+
+	t.Run("markdown options are parsed from the config", func(t *testing.T) {
+		conf, err := OpenConfig(writeConfig(t, "[markdown]\nanchors = false\nflags = [\"-href_target_blank\"]\n"))
+		if err != nil {
+			t.Fatalf("unable to open config %v", err.Error())
+		}
+
+		opts, err := conf.Markdown.Parse()
+		if err != nil {
+			t.Fatalf("unable to parse markdown options %v", err.Error())
+		}
+
+		if opts.Anchors || opts.Flags != md.DefaultOptions.Flags&^html.HrefTargetBlank {
+			t.Errorf("options should follow the config, got %+v", opts)
+		}
+	})
+
+	t.Run("environment tables & files are overlaid on the base", func(t *testing.T) {
+		fp := writeConfig(t, "[meta]\nname = \"Docs\"\nURL = \"http://localhost\"\n\n[env.production.meta]\nURL = \"https://example.com\"\n\n[env.staging.build]\nminfy = true\n")
+		overlay := EnvConfigPath(fp, "production")
+		if err := os.WriteFile(overlay, []byte("[build]\nminify = true\n"), 0644); err != nil {
+			t.Fatalf("unable to write overlay %v", err.Error())
+		}
+
+		c, err := OpenEnvConfig(fp, "production")
+		if err == nil || !strings.Contains(err.Error(), "unknown key env.staging.build.minfy, did you mean env.staging.build.minify?") {
+			t.Errorf("unselected environments should be checked too, got %v", err)
+		}
+
+		if c.Env != "production" || c.Metadata.Name != "Docs" || c.Metadata.URL != "https://example.com" {
+			t.Errorf("got env %v, name %v & url %v", c.Env, c.Metadata.Name, c.Metadata.URL)
+		}
+
+		if c.Sources["meta.URL"] != Source(fp+" [env.production]") {
+			t.Errorf("got %v", c.Sources["meta.URL"])
+		}
+
+		if !c.Build.Minify || c.Sources["build.minify"] != Source(overlay) {
+			t.Errorf("minify should come from %v, got %v (%v)", overlay, c.Build.Minify, c.Sources["build.minify"])
+		}
+
+		c, _ = OpenConfig(fp)
+		if c.Metadata.URL != "http://localhost" || c.Build.Minify {
+			t.Errorf("overlays should only apply to their environment, got %v %v", c.Metadata.URL, c.Build.Minify)
+		}
+
+		if _, err := OpenEnvConfig(fp, "nope"); err == nil || !strings.Contains(err.Error(), "unknown environment nope") {
+			t.Errorf("got %v", err)
+		}
+	})
+
+	t.Run("missing files fall back to the defaults", func(t *testing.T) {
+		c, err := OpenConfig(filepath.Join(t.TempDir(), "config.toml"))
+		if err != nil || c.Metadata.Name != NewDefaultConfig().Metadata.Name {
+			t.Errorf("got %v %v", c.Metadata.Name, err)
+		}
+	})
+}
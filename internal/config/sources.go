@@ -0,0 +1,204 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v3"
+)
+
+// type Source is where a config value came from: the defaults, a
+// config file (by path), an environment variable or a flag
+type Source string
+
+const SourceDefault Source = "default"
+
+// EnvPrefix is prepended to the upper-cased, underscore separated key
+// of a value to get the environment variable that overrides it, ex.
+// DOCUMANGO_DEV_PORT for dev.port
+const EnvPrefix = "DOCUMANGO_"
+
+// FlagKeys maps the flags shared by commands to the config values
+// they override
+var FlagKeys = map[string]string{
+	"content":   "dev.content_dir",
+	"templates": "dev.template_dir",
+	"static":    "dev.static_dir",
+	"port":      "dev.port",
+	"out":       "build.build_dir",
+	"strict":    "build.strict",
+	"drafts":    "build.drafts",
+	"future":    "build.future",
+}
+
+// type field is a settable value in the config & its dotted toml key
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+// function fields lists every value in the config, in the order the
+// tables & keys are declared
+func (c *Config) fields() []field {
+	return walkFields(reflect.ValueOf(c).Elem(), "")
+}
+
+func walkFields(v reflect.Value, prefix string) []field {
+	fields := []field{}
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("toml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		f := v.Field(i)
+		// Environment overlays aren't values of their own
+		if f.Type() == reflect.TypeOf(map[string]toml.Primitive{}) {
+			continue
+		}
+
+		if f.Kind() == reflect.Struct {
+			fields = append(fields, walkFields(f, prefix+name+".")...)
+			continue
+		}
+
+		fields = append(fields, field{key: prefix + name, value: f})
+	}
+
+	return fields
+}
+
+// function EnvName is the environment variable that overrides key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// function Set parses value into the config value at key. Lists are
+// comma separated.
+func (c *Config) Set(key, value string, source Source) error {
+	for _, f := range c.fields() {
+		if f.key != key {
+			continue
+		}
+
+		if err := setValue(f.value, value); err != nil {
+			return fmt.Errorf("unable to set %v from %v: %w", key, source, err)
+		}
+
+		if c.Sources == nil {
+			c.Sources = map[string]Source{}
+		}
+
+		c.Sources[key] = source
+		return nil
+	}
+
+	return fmt.Errorf("unknown config key %v", key)
+}
+
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+
+		v.Set(s)
+	default:
+		return fmt.Errorf("%v values can't be set from a string", v.Kind())
+	}
+
+	return nil
+}
+
+// function ApplyEnv overrides values with the DOCUMANGO_* variables in
+// environ (formatted as KEY=value, like os.Environ)
+func (c *Config) ApplyEnv(environ []string) error {
+	env := map[string]string{}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, EnvPrefix) {
+			env[k] = v
+		}
+	}
+
+	errs := []error{}
+	for _, f := range c.fields() {
+		name := EnvName(f.key)
+		value, ok := env[name]
+		if !ok {
+			continue
+		}
+
+		if err := c.Set(f.key, value, Source("env "+name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// function ApplyFlags overrides values with the flags in FlagKeys that
+// were set on the command line. It's the Before hook of every command,
+// so flags take precedence over the config file & environment.
+func ApplyFlags(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+	c, ok := ctx.Value(ConfKey).(*Config)
+	if !ok {
+		return ctx, nil
+	}
+
+	return ctx, c.SetFlags(cmd)
+}
+
+// function SetFlags overrides values with the flags in FlagKeys that
+// were set on cmd or its parents & validates the result
+func (c *Config) SetFlags(cmd *cli.Command) error {
+	set := false
+	for flag, key := range FlagKeys {
+		if !cmd.IsSet(flag) {
+			continue
+		}
+
+		if err := c.Set(key, fmt.Sprint(cmd.Value(flag)), Source("flag --"+flag)); err != nil {
+			return err
+		}
+
+		set = true
+	}
+
+	if set {
+		return c.Validate()
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/desertthunder/documango/internal/md"
+	"github.com/desertthunder/documango/internal/theme"
+)
+
+var (
+	tablePattern = regexp.MustCompile(`^\s*\[\[?\s*([^\]]+?)\s*\]\]?`)
+	keyPattern   = regexp.MustCompile(`^\s*"?([A-Za-z0-9_-]+)"?\s*=`)
+)
+
+var levels = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+// function Validate reports values that decode but can't work, like a
+// port out of range or a theme that doesn't exist
+func (c *Config) Validate() error {
+	errs := []error{}
+	invalid := func(key string, value any, reason string, args ...any) {
+		errs = append(errs, fmt.Errorf("%v = %#v (from %v): %v", key, value, c.source(key), fmt.Sprintf(reason, args...)))
+	}
+
+	if c.Options.Port < 1 || c.Options.Port > 65535 {
+		invalid("dev.port", c.Options.Port, "must be between 1 and 65535")
+	}
+
+	if !slices.Contains(levels, strings.ToUpper(c.Options.Level)) {
+		invalid("dev.level", c.Options.Level, "must be one of %v", strings.Join(levels, ", "))
+	}
+
+	for key, t := range map[string]string{"theme.light": c.Theme.Light, "theme.dark": c.Theme.Dark} {
+		variant := strings.TrimPrefix(key, "theme.")
+		if available := theme.List(variant); !slices.Contains(available, t) {
+			invalid(key, t, "no such %v theme, use one of %v", variant, strings.Join(available, ", "))
+		}
+	}
+
+	if c.Images.Quality < 1 || c.Images.Quality > 100 {
+		invalid("images.quality", c.Images.Quality, "must be between 1 and 100")
+	}
+
+	for _, w := range c.Images.Widths {
+		if w < 1 {
+			invalid("images.widths", c.Images.Widths, "widths must be positive")
+			break
+		}
+	}
+
+	if _, err := md.ParseOptions(c.Markdown.Extensions, nil); err != nil {
+		invalid("markdown.extensions", c.Markdown.Extensions, "%v", err.Error())
+	}
+
+	if _, err := md.ParseOptions(nil, c.Markdown.Flags); err != nil {
+		invalid("markdown.flags", c.Markdown.Flags, "%v", err.Error())
+	}
+
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func (c *Config) source(key string) Source {
+	if s, ok := c.Sources[key]; ok {
+		return s
+	}
+
+	return SourceDefault
+}
+
+// function unknownKeys reports the keys in the config file at p that
+// don't match a value in the config, with their line number & the
+// closest known key
+func (c *Config) unknownKeys(p, f string, meta toml.MetaData) []error {
+	undecoded := map[string]bool{}
+	for _, k := range meta.Undecoded() {
+		undecoded[k.String()] = true
+	}
+
+	known := []string{}
+	for _, field := range c.fields() {
+		known = append(known, field.key)
+	}
+
+	errs := []error{}
+	for _, k := range meta.Undecoded() {
+		key := k.String()
+		parent := k[:len(k)-1].String()
+
+		// Report unknown tables once, rather than each of their keys
+		if len(k) > 1 && undecoded[parent] {
+			continue
+		}
+
+		msg := fmt.Sprintf("unknown key %v", key)
+		if line := findLine(f, k); line > 0 {
+			msg = fmt.Sprintf("line %v: %v", line, msg)
+		}
+
+		// Suggest keys relative to their environment table
+		relative := key
+		if len(k) > 2 && k[0] == "env" {
+			relative = k[2:].String()
+		}
+
+		if to, ok := moved[relative]; ok {
+			msg += fmt.Sprintf(", it moved to %v", strings.TrimSuffix(key, relative)+to)
+		} else if s := suggest(relative, known); s != "" {
+			msg += fmt.Sprintf(", did you mean %v?", strings.TrimSuffix(key, relative)+s)
+		}
+
+		errs = append(errs, fmt.Errorf("%v: %v", p, msg))
+	}
+
+	return errs
+}
+
+// function findLine returns the line (starting at 1) the key is set on
+// or the line of its table header, 0 if it can't be found
+func findLine(f string, key toml.Key) int {
+	table := ""
+	parent := key[:len(key)-1].String()
+	name := key[len(key)-1]
+
+	for i, line := range strings.Split(f, "\n") {
+		if m := tablePattern.FindStringSubmatch(line); m != nil {
+			table = strings.ReplaceAll(m[1], " ", "")
+			if table == key.String() {
+				return i + 1
+			}
+
+			continue
+		}
+
+		if m := keyPattern.FindStringSubmatch(line); m != nil && m[1] == name && table == parent {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// function suggest returns the known key closest to key, if it's
+// within a couple of edits
+func suggest(key string, known []string) string {
+	best, distance := "", 3
+	for _, k := range known {
+		if d := levenshtein(key, k); d < distance {
+			best, distance = k, d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
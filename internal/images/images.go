@@ -0,0 +1,293 @@
+/*
+package images processes the images referenced in markdown content.
+
+Each image is decoded to find its dimensions and resized to every
+configured width smaller than the original, so that pages can render
+<img> tags with width, height, srcset & sizes. Optionally, WebP copies
+are encoded for browsers that support them. AVIF isn't supported since
+there's no pure Go encoder for it.
+
+Images next to the markdown file that references them are copied into
+the build's assets/_images directory with a content hash in their name.
+Images in the static directory (referenced as /assets/...) keep their
+URL. Remote images & SVGs are left untouched.
+
+Encoded variants are cached by the hash of their source, width, format
+and quality, so rebuilding a site doesn't resize unchanged images.
+*/
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
+	"golang.org/x/image/draw"
+)
+
+// OutputDir is where processed images are written, relative to the
+// build directory. It's kept apart from the static files (ex.
+// static/images), which are fingerprinted like any other asset.
+const OutputDir = "assets/_images"
+
+var supported = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+
+type Processor struct {
+	Options   config.ImageOptions
+	StaticDir string
+	BuildDir  string
+	// URL maps a path in the build directory to the URL it's served
+	// from (ex. prefixed with the path portion of meta.URL)
+	URL func(p string) string
+}
+
+// type source is a decoded image and the name its variants are
+// written under
+type source struct {
+	data   []byte
+	hash   string
+	name   string
+	config image.Config
+	img    image.Image
+}
+
+// function NewProcessor creates a processor for the site described by
+// conf. A nil url func serves images from the site root.
+func NewProcessor(conf *config.Config, url func(p string) string) *Processor {
+	if url == nil {
+		url = func(p string) string { return "/" + strings.TrimPrefix(p, "/") }
+	}
+
+	return &Processor{
+		Options:   conf.Images,
+		StaticDir: conf.Options.StaticDir,
+		BuildDir:  conf.Build.BuildDir,
+		URL:       url,
+	}
+}
+
+// function Resolve implements md.ImageResolver. dest is the image's
+// destination as written in the markdown file at fp.
+func (p *Processor) Resolve(dest, fp string) (*md.Image, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" {
+		return nil, nil
+	}
+
+	var path string
+	colocated := false
+	switch {
+	case strings.HasPrefix(u.Path, "/assets/"):
+		path = filepath.Join(p.StaticDir, strings.TrimPrefix(u.Path, "/assets/"))
+	case strings.HasPrefix(u.Path, "/"):
+		return nil, nil
+	default:
+		path = filepath.Join(filepath.Dir(fp), filepath.FromSlash(u.Path))
+		colocated = true
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if !supported[ext] {
+		return nil, nil
+	}
+
+	src, err := open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to process image %v %w", dest, err)
+	}
+
+	img := &md.Image{
+		Src:    dest,
+		Width:  src.config.Width,
+		Height: src.config.Height,
+	}
+
+	if colocated {
+		name := src.name + ext
+		if err := p.write(name, src.data); err != nil {
+			return nil, err
+		}
+
+		img.Src = p.URL(OutputDir + "/" + name)
+	}
+
+	// Resizing would drop every frame of an animated gif but the first
+	if ext == ".gif" {
+		return img, nil
+	}
+
+	widths := p.widths(src.config.Width)
+	if len(widths) > 0 {
+		srcset := []string{}
+		for _, w := range widths {
+			name, err := p.variant(src, w, ext)
+			if err != nil {
+				return nil, err
+			}
+
+			srcset = append(srcset, fmt.Sprintf("%v %vw", p.URL(OutputDir+"/"+name), w))
+		}
+
+		srcset = append(srcset, fmt.Sprintf("%v %vw", img.Src, src.config.Width))
+		img.Srcset = strings.Join(srcset, ", ")
+		img.Sizes = p.Options.Sizes
+	}
+
+	if p.Options.WebP && ext != ".webp" {
+		srcset := []string{}
+		for _, w := range append(widths, src.config.Width) {
+			name, err := p.variant(src, w, ".webp")
+			if err != nil {
+				return nil, err
+			}
+
+			srcset = append(srcset, fmt.Sprintf("%v %vw", p.URL(OutputDir+"/"+name), w))
+		}
+
+		img.Sources = []md.ImageSource{{Type: "image/webp", Srcset: strings.Join(srcset, ", ")}}
+		img.Sizes = p.Options.Sizes
+	}
+
+	return img, nil
+}
+
+// function widths returns the configured widths smaller than the
+// original, smallest first. Images are never upscaled.
+func (p *Processor) widths(original int) []int {
+	widths := []int{}
+	for _, w := range p.Options.Widths {
+		if w > 0 && w < original {
+			widths = append(widths, w)
+		}
+	}
+
+	sort.Ints(widths)
+	return widths
+}
+
+// function variant writes src resized to width w & encoded as ext to
+// the build directory, using the cache when possible, and returns its
+// file name
+func (p *Processor) variant(src *source, w int, ext string) (string, error) {
+	name := fmt.Sprintf("%v-%vw%v", src.name, w, ext)
+	key := fmt.Sprintf("%v-%v-%v%v", src.hash, w, p.Options.Quality, ext)
+
+	if p.Options.CacheDir != "" {
+		if data, err := os.ReadFile(filepath.Join(p.Options.CacheDir, key)); err == nil {
+			return name, p.write(name, data)
+		}
+	}
+
+	if src.img == nil {
+		img, _, err := image.Decode(bytes.NewReader(src.data))
+		if err != nil {
+			return "", fmt.Errorf("unable to decode image %v %w", src.name, err)
+		}
+
+		src.img = img
+	}
+
+	b := bytes.Buffer{}
+	if err := p.encode(&b, resize(src.img, w), ext); err != nil {
+		return "", fmt.Errorf("unable to encode %v %w", name, err)
+	}
+
+	if p.Options.CacheDir != "" {
+		if err := os.MkdirAll(p.Options.CacheDir, os.ModePerm); err == nil {
+			os.WriteFile(filepath.Join(p.Options.CacheDir, key), b.Bytes(), 0644)
+		}
+	}
+
+	return name, p.write(name, b.Bytes())
+}
+
+func (p *Processor) encode(w io.Writer, img image.Image, ext string) error {
+	switch ext {
+	case ".jpg", ".jpeg":
+		quality := p.Options.Quality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case ".png":
+		return png.Encode(w, img)
+	case ".gif":
+		return gif.Encode(w, img, nil)
+	case ".webp":
+		// nativewebp only writes lossless images, so quality doesn't
+		// apply
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported format %v", ext)
+	}
+}
+
+func (p *Processor) write(name string, data []byte) error {
+	dir := filepath.Join(p.BuildDir, filepath.FromSlash(OutputDir))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create %v %w", dir, err)
+	}
+
+	fp := filepath.Join(dir, name)
+	if info, err := os.Stat(fp); err == nil && info.Size() == int64(len(data)) {
+		return nil
+	}
+
+	if err := os.WriteFile(fp, data, 0644); err != nil {
+		return fmt.Errorf("unable to write %v %w", fp, err)
+	}
+
+	return nil
+}
+
+// function open reads the image at path & decodes its dimensions
+func open(path string) (*source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	return &source{
+		data:   data,
+		hash:   hash,
+		name:   fmt.Sprintf("%v-%v", stem, hash[:8]),
+		config: conf,
+	}, nil
+}
+
+// function resize scales img to width w, keeping its aspect ratio
+func resize(img image.Image, w int) image.Image {
+	b := img.Bounds()
+	h := (b.Dy()*w + b.Dx()/2) / b.Dx()
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
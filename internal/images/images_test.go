@@ -0,0 +1,139 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/documango/internal/config"
+)
+
+func writePNG(t *testing.T, fp string, w, h int) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+	f, err := os.Create(fp)
+	if err != nil {
+		t.Fatalf("unable to create %v %v", fp, err.Error())
+	}
+
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("unable to encode %v %v", fp, err.Error())
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "content", "guides", "diagram.png"), 200, 100)
+	writePNG(t, filepath.Join(dir, "static", "logo.png"), 40, 40)
+
+	conf := config.NewDefaultConfig()
+	conf.Options.StaticDir = filepath.Join(dir, "static")
+	conf.Build.BuildDir = filepath.Join(dir, "dist")
+	conf.Images.Widths = []int{100, 50, 400}
+	conf.Images.CacheDir = filepath.Join(dir, "cache")
+
+	fp := filepath.Join(dir, "content", "guides", "setup.md")
+	p := NewProcessor(&conf, func(p string) string { return "/docs/" + p })
+
+	t.Run("co-located images are copied with their dimensions & variants", func(t *testing.T) {
+		img, err := p.Resolve("./diagram.png", fp)
+		if err != nil {
+			t.Fatalf("unable to process image %v", err.Error())
+		}
+
+		if img.Width != 200 || img.Height != 100 {
+			t.Errorf("got %vx%v, want 200x100", img.Width, img.Height)
+		}
+
+		if !strings.HasPrefix(img.Src, "/docs/assets/_images/diagram-") {
+			t.Errorf("image should be hashed & served from assets %v", img.Src)
+		}
+
+		candidates := strings.Split(img.Srcset, ", ")
+		if len(candidates) != 3 || !strings.HasSuffix(candidates[0], " 50w") || !strings.HasSuffix(candidates[2], " 200w") {
+			t.Errorf("srcset should have 50w, 100w & the original without upscaling %v", img.Srcset)
+		}
+
+		variants, _ := filepath.Glob(filepath.Join(conf.Build.BuildDir, OutputDir, "diagram-*"))
+		if len(variants) != 3 {
+			t.Errorf("the original & 2 variants should be written, got %v", variants)
+		}
+
+		resized, err := os.Open(filepath.Join(conf.Build.BuildDir, OutputDir, strings.TrimPrefix(strings.Fields(candidates[0])[0], "/docs/assets/_images/")))
+		if err != nil {
+			t.Fatalf("unable to open variant %v", err.Error())
+		}
+
+		defer resized.Close()
+
+		if c, _, err := image.DecodeConfig(resized); err != nil || c.Width != 50 || c.Height != 25 {
+			t.Errorf("variant should be 50x25, got %v %v", c, err)
+		}
+	})
+
+	t.Run("variants are cached between builds", func(t *testing.T) {
+		cached, _ := os.ReadDir(conf.Images.CacheDir)
+		if len(cached) != 2 {
+			t.Fatalf("there should be 2 cached variants, got %v", len(cached))
+		}
+
+		os.RemoveAll(conf.Build.BuildDir)
+		if _, err := p.Resolve("./diagram.png", fp); err != nil {
+			t.Fatalf("unable to process image %v", err.Error())
+		}
+
+		variants, _ := filepath.Glob(filepath.Join(conf.Build.BuildDir, OutputDir, "diagram-*"))
+		if len(variants) != 3 {
+			t.Errorf("cached variants should be copied to the build, got %v", variants)
+		}
+	})
+
+	t.Run("static images keep their url", func(t *testing.T) {
+		img, err := p.Resolve("/assets/logo.png", fp)
+		if err != nil {
+			t.Fatalf("unable to process image %v", err.Error())
+		}
+
+		if img.Src != "/assets/logo.png" || img.Width != 40 || img.Srcset != "" {
+			t.Errorf("got %+v", img)
+		}
+	})
+
+	t.Run("webp sources are generated when enabled", func(t *testing.T) {
+		webp := *p
+		webp.Options.WebP = true
+
+		img, err := webp.Resolve("./diagram.png", fp)
+		if err != nil {
+			t.Fatalf("unable to process image %v", err.Error())
+		}
+
+		if len(img.Sources) != 1 || img.Sources[0].Type != "image/webp" || !strings.Contains(img.Sources[0].Srcset, ".webp 200w") {
+			t.Errorf("got %+v", img.Sources)
+		}
+	})
+
+	t.Run("remote images & svgs are skipped, missing images fail", func(t *testing.T) {
+		for _, dest := range []string{"https://example.com/x.png", "./icon.svg", "/images/x.png"} {
+			if img, err := p.Resolve(dest, fp); img != nil || err != nil {
+				t.Errorf("%v should be skipped, got %v %v", dest, img, err)
+			}
+		}
+
+		if _, err := p.Resolve("./nope.png", fp); err == nil {
+			t.Error("missing images should be an error")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package md
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// GitHub alerts, ex. > [!WARNING]
+	alertPattern = regexp.MustCompile(`^ {0,3}>[ \t]*\[!(NOTE|TIP|IMPORTANT|WARNING|CAUTION)\][ \t]*(.*?)[ \t]*$`)
+	quotePattern = regexp.MustCompile(`^ {0,3}> ?`)
+	// Containers, ex. ::: note Optional title
+	containerOpen  = regexp.MustCompile(`^ {0,3}(:{3,})[ \t]*([A-Za-z][\w-]*)[ \t]*(.*?)[ \t]*$`)
+	containerClose = regexp.MustCompile(`^ {0,3}(:{3,})[ \t]*$`)
+)
+
+// function expandCallouts rewrites GitHub alerts & ::: containers as
+// note shortcodes, so that every callout is rendered by the same
+// (overridable) template:
+//
+//	> [!TIP]              ::: tip Optional title
+//	> Use --strict in CI  Use --strict in CI
+//	                      :::
+//
+// Containers can be nested by using more colons for the outer one.
+// Callouts in fenced code blocks are left as is.
+func expandCallouts(content []byte) []byte {
+	if !bytes.Contains(content, []byte("[!")) && !bytes.Contains(content, []byte(":::")) {
+		return content
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	fences := fencedRanges(content)
+	out := strings.Builder{}
+	// Colons of the open containers, innermost last
+	open := []string{}
+	offset := 0
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		start := offset
+		offset += len(line)
+
+		if _, fenced := inFence(start, fences); fenced {
+			out.WriteString(line)
+			continue
+		}
+
+		text := strings.TrimRight(line, "\r\n")
+		if m := alertPattern.FindStringSubmatch(text); m != nil {
+			out.WriteString(calloutTag(strings.ToLower(m[1]), m[2]))
+			for i+1 < len(lines) && quotePattern.MatchString(lines[i+1]) {
+				i++
+				offset += len(lines[i])
+				out.WriteString(quotePattern.ReplaceAllString(lines[i], ""))
+			}
+
+			out.WriteString(closeCallout(lines[i]))
+			continue
+		}
+
+		if m := containerClose.FindStringSubmatch(text); m != nil && len(open) > 0 {
+			// A closing fence closes the innermost container it's long
+			// enough for, along with any unclosed containers in it
+			closed := false
+			for len(open) > 0 && len(m[1]) >= len(open[len(open)-1]) {
+				open = open[:len(open)-1]
+				out.WriteString("\n{{< /note >}}\n")
+				closed = true
+			}
+
+			if closed {
+				continue
+			}
+		}
+
+		if m := containerOpen.FindStringSubmatch(text); m != nil {
+			open = append(open, m[1])
+			out.WriteString(calloutTag(strings.ToLower(m[2]), m[3]))
+			continue
+		}
+
+		out.WriteString(line)
+	}
+
+	for range open {
+		out.WriteString("\n{{< /note >}}\n")
+	}
+
+	return []byte(out.String())
+}
+
+// function calloutTag opens a note shortcode for a callout of type t
+func calloutTag(t, title string) string {
+	tag := "{{< note type=" + strconv.Quote(t)
+	if title != "" {
+		tag += " title=" + strconv.Quote(title)
+	}
+
+	return tag + " >}}\n"
+}
+
+// function closeCallout closes a note shortcode after the last line of
+// an alert
+func closeCallout(last string) string {
+	if strings.HasSuffix(last, "\n") {
+		return "{{< /note >}}\n"
+	}
+
+	return "\n{{< /note >}}"
+}
@@ -0,0 +1,179 @@
+package md
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"html/template"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// DiagramTimeout limits how long an executable can take to render a
+// diagram
+var DiagramTimeout = 10 * time.Second
+
+// ErrNoRenderer is returned by a DiagramRenderer that can't render a
+// diagram, ex. when its executable isn't installed. The fence is
+// rendered as a code block, without a warning.
+var ErrNoRenderer = errors.New("diagram renderer not available")
+
+// type DiagramRenderer renders the source of a fenced code block to
+// HTML, ex. an inline <svg>
+type DiagramRenderer func(source []byte) ([]byte, error)
+
+var diagrams = struct {
+	sync.RWMutex
+	renderers map[string]DiagramRenderer
+}{renderers: map[string]DiagramRenderer{
+	"dot":      Executable("dot", "-Tsvg"),
+	"plantuml": Executable("plantuml", "-tsvg", "-pipe"),
+}}
+
+// function RegisterDiagram renders fences tagged lang with r. A nil
+// renderer removes it, so the fences are rendered as code.
+func RegisterDiagram(lang string, r DiagramRenderer) {
+	diagrams.Lock()
+	defer diagrams.Unlock()
+
+	if r == nil {
+		delete(diagrams.renderers, lang)
+	} else {
+		diagrams.renderers[lang] = r
+	}
+}
+
+func diagramRenderer(lang string) (DiagramRenderer, bool) {
+	diagrams.RLock()
+	defer diagrams.RUnlock()
+
+	r, ok := diagrams.renderers[lang]
+	return r, ok
+}
+
+// function Executable is a DiagramRenderer that pipes the source of
+// a diagram to the named executable and inlines the SVG it writes to
+// stdout. It returns ErrNoRenderer when the executable isn't on PATH.
+//
+// SVGs are cached by the hash of their source, so unchanged diagrams
+// aren't rendered again, ex. when the dev server reloads a page.
+func Executable(name string, args ...string) DiagramRenderer {
+	cache := sync.Map{}
+
+	return func(source []byte) ([]byte, error) {
+		key := sha256.Sum256(source)
+		if svg, ok := cache.Load(key); ok {
+			return svg.([]byte), nil
+		}
+
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return nil, ErrNoRenderer
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), DiagramTimeout)
+		defer cancel()
+
+		stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+		cmd := exec.CommandContext(ctx, path, args...)
+		cmd.Stdin = bytes.NewReader(source)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("%v %w", msg, err)
+			}
+
+			return nil, err
+		}
+
+		// Drop the XML declaration & doctype before the <svg> element
+		svg := stdout.Bytes()
+		if i := bytes.Index(svg, []byte("<svg")); i > 0 {
+			svg = svg[i:]
+		}
+
+		cache.Store(key, svg)
+		return svg, nil
+	}
+}
+
+// function HasMermaid reports whether rendered HTML contains mermaid
+// diagrams, which need the mermaid script to be drawn
+func HasMermaid(html []byte) bool {
+	return bytes.Contains(html, []byte(`<pre class="mermaid">`))
+}
+
+// function processDiagrams replaces diagram fences with HTML. Mermaid
+// diagrams are drawn in the browser, so they're kept as text in a
+// <pre class="mermaid">. Fences with a registered DiagramRenderer
+// (ex. dot) are rendered when the page is built and wrapped in a
+// <figure>. Failed diagrams are rendered as code & reported.
+func (m MD) processDiagrams(doc ast.Node) []Warning {
+	warnings := []Warning{}
+	blocks := map[*ast.CodeBlock][]byte{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		block, ok := node.(*ast.CodeBlock)
+		if !ok || !entering || !block.IsFenced {
+			return ast.GoToNext
+		}
+
+		lang := strings.Fields(string(block.Info) + " ")[0]
+		if lang == "mermaid" {
+			blocks[block] = []byte(`<pre class="mermaid">` + template.HTMLEscapeString(string(block.Literal)) + "</pre>\n")
+			return ast.GoToNext
+		}
+
+		render, ok := diagramRenderer(lang)
+		if !ok {
+			return ast.GoToNext
+		}
+
+		svg, err := render(block.Literal)
+		if errors.Is(err, ErrNoRenderer) {
+			return ast.GoToNext
+		}
+
+		if err != nil {
+			warnings = append(warnings, Warning{
+				FilePath: m.FilePath,
+				Message:  fmt.Sprintf("unable to render %v diagram: %v", lang, err.Error()),
+			})
+
+			return ast.GoToNext
+		}
+
+		blocks[block] = []byte(fmt.Sprintf("<figure class=\"diagram diagram-%v\">%s</figure>\n", template.HTMLEscapeString(lang), bytes.TrimSpace(svg)))
+		return ast.GoToNext
+	})
+
+	for block, html := range blocks {
+		replaceNode(block, &ast.HTMLBlock{Leaf: ast.Leaf{Literal: html}})
+	}
+
+	return warnings
+}
+
+// function replaceNode puts node in the place of old in the tree
+func replaceNode(old, node ast.Node) {
+	parent := old.GetParent()
+	if parent == nil {
+		return
+	}
+
+	children := parent.GetChildren()
+	for i, child := range children {
+		if child == old {
+			children[i] = node
+			node.SetParent(parent)
+			return
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package md
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// ParserExtensions are the gomarkdown parser extensions, by the names
+// used in the markdown.extensions config key
+var ParserExtensions = map[string]parser.Extensions{
+	"common":                     parser.CommonExtensions,
+	"no_intra_emphasis":          parser.NoIntraEmphasis,
+	"tables":                     parser.Tables,
+	"fenced_code":                parser.FencedCode,
+	"autolink":                   parser.Autolink,
+	"strikethrough":              parser.Strikethrough,
+	"lax_html_blocks":            parser.LaxHTMLBlocks,
+	"space_headings":             parser.SpaceHeadings,
+	"hard_line_break":            parser.HardLineBreak,
+	"non_blocking_space":         parser.NonBlockingSpace,
+	"tab_size_eight":             parser.TabSizeEight,
+	"footnotes":                  parser.Footnotes,
+	"no_empty_line_before_block": parser.NoEmptyLineBeforeBlock,
+	"heading_ids":                parser.HeadingIDs,
+	"titleblock":                 parser.Titleblock,
+	"auto_heading_ids":           parser.AutoHeadingIDs,
+	"backslash_line_break":       parser.BackslashLineBreak,
+	"definition_lists":           parser.DefinitionLists,
+	"mathjax":                    parser.MathJax,
+	"ordered_list_start":         parser.OrderedListStart,
+	"attributes":                 parser.Attributes,
+	"super_subscript":            parser.SuperSubscript,
+	"empty_lines_break_list":     parser.EmptyLinesBreakList,
+}
+
+// RendererFlags are the gomarkdown HTML renderer flags, by the names
+// used in the markdown.flags config key
+var RendererFlags = map[string]html.Flags{
+	"common":                    html.CommonFlags,
+	"skip_html":                 html.SkipHTML,
+	"skip_images":               html.SkipImages,
+	"skip_links":                html.SkipLinks,
+	"safelink":                  html.Safelink,
+	"nofollow_links":            html.NofollowLinks,
+	"noreferrer_links":          html.NoreferrerLinks,
+	"noopener_links":            html.NoopenerLinks,
+	"href_target_blank":         html.HrefTargetBlank,
+	"use_xhtml":                 html.UseXHTML,
+	"footnote_return_links":     html.FootnoteReturnLinks,
+	"footnote_no_hr_tag":        html.FootnoteNoHRTag,
+	"smartypants":               html.Smartypants,
+	"smartypants_fractions":     html.SmartypantsFractions,
+	"smartypants_dashes":        html.SmartypantsDashes,
+	"smartypants_latex_dashes":  html.SmartypantsLatexDashes,
+	"smartypants_angled_quotes": html.SmartypantsAngledQuotes,
+	"smartypants_quotes_nbsp":   html.SmartypantsQuotesNBSP,
+	"lazy_load_images":          html.LazyLoadImages,
+}
+
+// type Options are the parser extensions & renderer flags markdown is
+// rendered with. Anchors adds "¶" permalinks to h2-h4 headings.
+type Options struct {
+	Extensions parser.Extensions
+	Flags      html.Flags
+	Anchors    bool
+}
+
+// DefaultOptions are used when an MD has no Options
+var DefaultOptions = Options{
+	Extensions: parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock,
+	Flags:      html.CommonFlags | html.HrefTargetBlank | html.LazyLoadImages,
+	Anchors:    true,
+}
+
+// function ParseOptions reads parser extensions & renderer flags by
+// name (see ParserExtensions & RendererFlags). Names prefixed with a
+// dash are removed, ex. ["common", "-mathjax"]. The defaults are used
+// for an empty list, and lists that only remove names are applied to
+// the defaults, ex. ["-href_target_blank"].
+func ParseOptions(extensions, flags []string) (Options, error) {
+	opts := DefaultOptions
+	var err error
+
+	if len(extensions) > 0 {
+		if opts.Extensions, err = parseNames(extensions, ParserExtensions, opts.Extensions); err != nil {
+			return opts, fmt.Errorf("unknown parser extension %w", err)
+		}
+	}
+
+	if len(flags) > 0 {
+		if opts.Flags, err = parseNames(flags, RendererFlags, opts.Flags); err != nil {
+			return opts, fmt.Errorf("unknown renderer flag %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// function parseNames combines the values of names, starting from
+// defaults when every name is a removal
+func parseNames[T ~int](names []string, values map[string]T, defaults T) (T, error) {
+	var set T
+	if !slices.ContainsFunc(names, func(name string) bool { return !strings.HasPrefix(name, "-") }) {
+		set = defaults
+	}
+
+	for _, name := range names {
+		remove := strings.HasPrefix(name, "-")
+		value, ok := values[strings.TrimPrefix(name, "-")]
+		if !ok {
+			known := make([]string, 0, len(values))
+			for k := range values {
+				known = append(known, k)
+			}
+
+			sort.Strings(known)
+			return set, fmt.Errorf("%v, use one of %v", name, strings.Join(known, ", "))
+		}
+
+		if remove {
+			set &^= value
+		} else {
+			set |= value
+		}
+	}
+
+	return set, nil
+}
+
+// type RenderHook renders the tags of a node in place of the default
+// renderer r, returning false to leave the node to it. Hooks are called
+// when entering & leaving a node, and its children are rendered either
+// way, so a hook can wrap a node by writing around calls to r (ex.
+// r.Heading(w, node, entering)).
+type RenderHook[N ast.Node] func(r *html.Renderer, w io.Writer, node N, entering bool) bool
+
+// type Extension customizes how markdown is rendered. Transforms run
+// on the parsed document after links, images & diagrams are processed,
+// and hooks run before the built-in ones.
+type Extension struct {
+	Name string
+	// Transform modifies the document & reports problems as warnings
+	Transform func(m MD, doc ast.Node) []Warning
+	Heading   RenderHook[*ast.Heading]
+	Link      RenderHook[*ast.Link]
+	Image     RenderHook[*ast.Image]
+	CodeBlock RenderHook[*ast.CodeBlock]
+	Table     RenderHook[*ast.Table]
+}
+
+var registry = struct {
+	sync.RWMutex
+	extensions []Extension
+}{}
+
+// function Register adds an extension to every render, replacing the
+// registered extension with the same name
+func Register(ext Extension) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for i, e := range registry.extensions {
+		if e.Name == ext.Name {
+			registry.extensions[i] = ext
+			return
+		}
+	}
+
+	registry.extensions = append(registry.extensions, ext)
+}
+
+// function Unregister removes the named extension
+func Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for i, e := range registry.extensions {
+		if e.Name == name {
+			registry.extensions = append(registry.extensions[:i:i], registry.extensions[i+1:]...)
+			return
+		}
+	}
+}
+
+// function extensions returns the registered extensions followed by
+// the file's own
+func (m MD) extensions() []Extension {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return append(append([]Extension{}, registry.extensions...), m.Extensions...)
+}
+
+// function options returns the file's options or the defaults
+func (m MD) options() Options {
+	if m.Options != nil {
+		return *m.Options
+	}
+
+	return DefaultOptions
+}
+
+// function hook adapts the typed hooks of an extension to a gomarkdown
+// render hook
+func (e Extension) hook(r *html.Renderer) html.RenderNodeFunc {
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		rendered := false
+		switch n := node.(type) {
+		case *ast.Heading:
+			rendered = e.Heading != nil && e.Heading(r, w, n, entering)
+		case *ast.Link:
+			rendered = e.Link != nil && e.Link(r, w, n, entering)
+		case *ast.Image:
+			rendered = e.Image != nil && e.Image(r, w, n, entering)
+		case *ast.CodeBlock:
+			rendered = e.CodeBlock != nil && e.CodeBlock(r, w, n, entering)
+		case *ast.Table:
+			rendered = e.Table != nil && e.Table(r, w, n, entering)
+		}
+
+		return ast.GoToNext, rendered
+	}
+}
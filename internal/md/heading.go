@@ -0,0 +1,74 @@
+package md
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// function checkHeadingIDs reports headings that share an id. The
+// parser numbers duplicate generated ids (ex. setup, setup-1), but not
+// ids set with {#id}, which the renderer renames instead, breaking
+// links to them. Shortcodes & callouts are parsed separately from the
+// page, so seen records the ids (& titles) found across every part.
+func (m MD) checkHeadingIDs(doc ast.Node, seen map[string]string) []Warning {
+	warnings := []Warning{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering || heading.HeadingID == "" {
+			return ast.GoToNext
+		}
+
+		title := headingText(heading)
+		if first, ok := seen[heading.HeadingID]; ok {
+			warnings = append(warnings, Warning{
+				FilePath: m.FilePath,
+				Message:  fmt.Sprintf("duplicate heading id #%v (%q & %q)", heading.HeadingID, first, title),
+			})
+		} else {
+			seen[heading.HeadingID] = title
+		}
+
+		return ast.GoToNext
+	})
+
+	return warnings
+}
+
+// function anchorHook adds a "¶" permalink to h2, h3 & h4 headings,
+// before their closing tag
+func anchorHook(r *html.Renderer) html.RenderNodeFunc {
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		heading, ok := node.(*ast.Heading)
+		if !ok || entering || heading.Level < 2 || heading.Level > 4 || heading.HeadingID == "" {
+			return ast.GoToNext, false
+		}
+
+		fmt.Fprintf(w, ` <a class="heading-anchor" href="#%v" aria-label="Link to %v">¶</a>`,
+			template.HTMLEscapeString(heading.HeadingID), template.HTMLEscapeString(headingText(heading)))
+		r.Heading(w, heading, entering)
+		return ast.GoToNext, true
+	}
+}
+
+// function headingText is the plain text of a heading
+func headingText(heading *ast.Heading) string {
+	text := strings.Builder{}
+	ast.WalkFunc(heading, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			switch node.(type) {
+			case *ast.Text, *ast.Code, *ast.Math:
+				text.Write(leaf.Literal)
+			}
+		}
+
+		return ast.GoToNext
+	})
+
+	return strings.TrimSpace(text.String())
+}
@@ -0,0 +1,96 @@
+package md
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// displayMath marks inline $$…$$ spans, which are typeset as display
+// math inside of the paragraph
+var displayMath = []byte("display")
+
+// function inlineMath parses $…$ & $$…$$ spans. It replaces the parser
+// of the MathJax extension, which doesn't handle $$…$$ in a paragraph
+// and reads prices (ex. $5 and $10) as math. Like pandoc, the opening
+// $ can't be followed by a space and the closing $ can't be preceded
+// by a space or followed by a digit. \$ is a literal dollar sign and
+// math can't contain code spans.
+func inlineMath(p *parser.Parser, data []byte, offset int) (int, ast.Node) {
+	data = data[offset:]
+
+	delim := 1
+	if len(data) > 1 && data[1] == '$' {
+		delim = 2
+	}
+
+	if len(data) <= delim*2 || isSpace(data[delim]) {
+		return 0, nil
+	}
+
+	for end := delim + 1; end+delim <= len(data); end++ {
+		// Code spans take precedence
+		if data[end-1] == '`' {
+			return 0, nil
+		}
+
+		if data[end-1] == '\\' || !bytes.HasPrefix(data[end:], []byte("$$")[:delim]) {
+			continue
+		}
+
+		if isSpace(data[end-1]) || (end+delim < len(data) && isDigit(data[end+delim])) {
+			continue
+		}
+
+		math := &ast.Math{}
+		math.Literal = data[delim:end]
+		if delim == 2 {
+			math.Attribute = &ast.Attribute{Classes: [][]byte{displayMath}}
+		}
+
+		return end + delim, math
+	}
+
+	return 0, nil
+}
+
+// function mathHook renders math as spans & blocks that KaTeX or
+// MathJax typeset in the browser, using the \( \) & \[ \] delimiters
+// they recognize
+func mathHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	switch n := node.(type) {
+	case *ast.Math:
+		if n.Attribute != nil && len(n.Attribute.Classes) > 0 && bytes.Equal(n.Attribute.Classes[0], displayMath) {
+			io.WriteString(w, `<span class="math display">\[`)
+			html.EscapeHTML(w, n.Literal)
+			io.WriteString(w, `\]</span>`)
+		} else {
+			io.WriteString(w, `<span class="math inline">\(`)
+			html.EscapeHTML(w, n.Literal)
+			io.WriteString(w, `\)</span>`)
+		}
+
+		return ast.GoToNext, true
+	case *ast.MathBlock:
+		if entering {
+			io.WriteString(w, `<div class="math display">\[`)
+			html.EscapeHTML(w, bytes.TrimSpace(n.Literal))
+			io.WriteString(w, "\\]</div>\n")
+		}
+
+		return ast.SkipChildren, true
+	}
+
+	return ast.GoToNext, false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
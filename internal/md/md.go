@@ -7,14 +7,19 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/desertthunder/documango/internal/utils"
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"gopkg.in/yaml.v3"
@@ -24,15 +29,118 @@ import (
 var SampleContentDir embed.FS
 
 type Frontmatter struct {
-	Title  string `toml:"title" yaml:"title"`
-	Layout string `toml:"layout" yaml:"layout"`
-	Draft  bool   `toml:"draft" yaml:"draft"`
+	Title   string    `toml:"title" yaml:"title"`
+	Layout  string    `toml:"layout" yaml:"layout"`
+	Draft   bool      `toml:"draft" yaml:"draft"`
+	Date    time.Time `toml:"date" yaml:"date"`
+	Tags    []string  `toml:"tags" yaml:"tags"`
+	Summary string    `toml:"summary" yaml:"summary"`
+	// Navigation & ordering. Order is an alias for Weight and SortBy
+	// on a section's index sets how its pages are ordered.
+	Weight     int      `toml:"weight" yaml:"weight"`
+	Order      int      `toml:"order" yaml:"order"`
+	SortBy     string   `toml:"sort_by" yaml:"sort_by"`
+	NavTitle   string   `toml:"nav_title" yaml:"nav_title"`
+	Menus      []string `toml:"menus" yaml:"menus"`
+	NavExclude bool     `toml:"nav_exclude" yaml:"nav_exclude"`
+	// Pages are published from PublishDate until ExpiryDate, when set
+	PublishDate time.Time `toml:"publishDate" yaml:"publishDate"`
+	ExpiryDate  time.Time `toml:"expiryDate" yaml:"expiryDate"`
+	// Math includes the assets that typeset $…$ & $$…$$ on the page
+	Math bool `toml:"math" yaml:"math"`
+}
+
+// Reasons a page isn't published, see Frontmatter.Unpublished
+const (
+	Draft     = "draft"
+	Scheduled = "scheduled"
+	Expired   = "expired"
+)
+
+// type Preview selects the unpublished pages that are read anyway, so
+// authors can preview them with --drafts & --future
+type Preview struct {
+	Drafts bool
+	Future bool
+	// Now is the time publish & expiry dates are compared to, it
+	// defaults to the current time
+	Now time.Time
+}
+
+// function Includes reports whether the preview includes pages that
+// are unpublished for every one of reasons. Expired pages are never
+// included.
+func (p Preview) Includes(reasons []string) bool {
+	for _, reason := range reasons {
+		switch reason {
+		case Draft:
+			if !p.Drafts {
+				return false
+			}
+		case Scheduled:
+			if !p.Future {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
 }
 
 type MD struct {
 	FilePath    string
 	Frontmatter *Frontmatter
 	Content     []byte
+	// Resolver rewrites relative links to other markdown files. Links
+	// are left untouched when it is nil.
+	Resolver LinkResolver
+	// Images processes the images referenced in the file
+	Images ImageResolver
+	// ShortcodeDir holds shortcode templates ({name}.html) that take
+	// precedence over DefaultShortcodes
+	ShortcodeDir string
+	// Options are the parser extensions & renderer flags, DefaultOptions
+	// when nil
+	Options *Options
+	// Extensions are used after the registered extensions (see Register)
+	Extensions []Extension
+}
+
+// type LinkResolver maps the path of a markdown file to the route it
+// is built to. The returned bool is false when no page is built from
+// the file.
+type LinkResolver func(fp string) (string, bool)
+
+// type Image describes the attributes of a processed image. Sources
+// are alternative formats (ex. WebP) that are rendered in a <picture>
+type Image struct {
+	Src     string
+	Width   int
+	Height  int
+	Srcset  string
+	Sizes   string
+	Sources []ImageSource
+}
+
+type ImageSource struct {
+	Type   string
+	Srcset string
+}
+
+// type ImageResolver processes the image at dest, referenced in the
+// markdown file at fp. A nil Image leaves the image untouched.
+type ImageResolver func(dest, fp string) (*Image, error)
+
+// type Warning is a non-fatal problem found while rendering a file
+type Warning struct {
+	FilePath string
+	Message  string
+}
+
+func (w Warning) Error() string {
+	return fmt.Sprintf("%v: %v", w.FilePath, w.Message)
 }
 
 func SplitFrontmatter(content []byte) (*Frontmatter, []byte, error) {
@@ -90,6 +198,39 @@ func SplitFrontmatter(content []byte) (*Frontmatter, []byte, error) {
 	return &t, bytes.TrimSpace(b.Bytes()), nil
 }
 
+// function Unpublished lists every reason the page isn't published at
+// now: it's a Draft, Scheduled (publishDate is in the future) and/or
+// Expired (expiryDate has passed). It's empty for published pages.
+func (f *Frontmatter) Unpublished(now time.Time) []string {
+	reasons := []string{}
+	if f == nil {
+		return reasons
+	}
+
+	if f.Draft {
+		reasons = append(reasons, Draft)
+	}
+
+	if !f.PublishDate.IsZero() && f.PublishDate.After(now) {
+		reasons = append(reasons, Scheduled)
+	}
+
+	if !f.ExpiryDate.IsZero() && !f.ExpiryDate.After(now) {
+		reasons = append(reasons, Expired)
+	}
+
+	return reasons
+}
+
+// function PageWeight is the page's weight, falling back to its order
+func (f Frontmatter) PageWeight() int {
+	if f.Weight != 0 {
+		return f.Weight
+	}
+
+	return f.Order
+}
+
 func OpenContentFile(fp string) (*MD, error) {
 	var md MD
 	data, err := os.ReadFile(fp)
@@ -114,8 +255,13 @@ func OpenContentFile(fp string) (*MD, error) {
 }
 
 // ReadContentDirectory recursively calls constructors on a
-// provided directory and creates pointers to views
-func ReadContentDirectory(dir string, tdir string) ([]*MD, error) {
+// provided directory and creates pointers to views. Unpublished
+// files are skipped unless they're included by preview.
+func ReadContentDirectory(dir string, tdir string, preview Preview) ([]*MD, error) {
+	if preview.Now.IsZero() {
+		preview.Now = time.Now()
+	}
+
 	entries, err := os.ReadDir(dir)
 	mdFiles := []*MD{}
 	if err != nil && os.IsNotExist(err) {
@@ -137,7 +283,7 @@ func ReadContentDirectory(dir string, tdir string) ([]*MD, error) {
 	for _, entry := range entries {
 		fpath := fmt.Sprintf("%v/%v", dir, entry.Name())
 		if entry.IsDir() {
-			nestedMD, err := ReadContentDirectory(fpath, tdir)
+			nestedMD, err := ReadContentDirectory(fpath, tdir, preview)
 			if err != nil && len(nestedMD) == 0 {
 				return []*MD{}, err
 			}
@@ -155,8 +301,7 @@ func ReadContentDirectory(dir string, tdir string) ([]*MD, error) {
 			return []*MD{}, err
 		}
 
-		if mdFile == nil ||
-			(mdFile.Frontmatter != nil && mdFile.Frontmatter.Draft) {
+		if mdFile == nil || !preview.Includes(mdFile.Frontmatter.Unpublished(preview.Now)) {
 			continue
 		}
 
@@ -167,9 +312,237 @@ func ReadContentDirectory(dir string, tdir string) ([]*MD, error) {
 	return mdFiles, nil
 }
 
+// function Escape backslash escapes the markdown punctuation (& HTML
+// tags) in text, so that it's rendered as is when it's written into
+// markdown, ex. a page summary in a section listing. Line breaks are
+// replaced with spaces.
+func Escape(text string) string {
+	b := strings.Builder{}
+	for _, r := range strings.Join(strings.Fields(text), " ") {
+		if r < 128 && bytes.IndexByte(parser.EscapeChars, byte(r)) >= 0 {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
 func (m MD) HTML() []byte {
-	p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock)
-	renderer := html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags | html.HrefTargetBlank})
-	doc := p.Parse(m.Content)
+	data, _ := m.Render()
+	return data
+}
+
+// function Render converts the markdown content to HTML and reports
+// any problems (ex. links to missing files) as warnings. Shortcodes are
+// expanded before the content is parsed, see Shortcode, and callouts
+// (GitHub alerts & ::: containers) are rendered by the note shortcode.
+func (m MD) Render() ([]byte, []Warning) {
+	shortcodes := newShortcodeRenderer(m)
+	doc := m.parse(shortcodes.expand(expandCallouts(m.Content), nil))
+	pictures, warnings := m.transform(doc)
+	warnings = append(warnings, m.checkHeadingIDs(doc, shortcodes.headings)...)
+
+	return shortcodes.replace(m.render(doc, pictures)), append(shortcodes.warnings, warnings...)
+}
+
+// function transform rewrites links, processes images & renders diagrams
+// in a parsed document, then applies the transforms of the extensions
+func (m MD) transform(doc ast.Node) (map[*ast.Image][]ImageSource, []Warning) {
+	warnings := m.rewriteLinks(doc)
+
+	pictures, imageWarnings := m.processImages(doc)
+	warnings = append(warnings, imageWarnings...)
+	warnings = append(warnings, m.processDiagrams(doc)...)
+
+	for _, ext := range m.extensions() {
+		if ext.Transform != nil {
+			warnings = append(warnings, ext.Transform(m, doc)...)
+		}
+	}
+
+	return pictures, warnings
+}
+
+func (m MD) parse(content []byte) ast.Node {
+	p := parser.NewWithExtensions(m.options().Extensions)
+	if m.options().Extensions&parser.MathJax != 0 {
+		p.RegisterInline('$', inlineMath)
+	}
+
+	return p.Parse(content)
+}
+
+func (m MD) render(doc ast.Node, pictures map[*ast.Image][]ImageSource) []byte {
+	renderer := html.NewRenderer(html.RendererOptions{Flags: m.options().Flags})
+
+	hooks := []html.RenderNodeFunc{}
+	for _, ext := range m.extensions() {
+		hooks = append(hooks, ext.hook(renderer))
+	}
+
+	if m.options().Anchors {
+		hooks = append(hooks, anchorHook(renderer))
+	}
+
+	hooks = append(hooks, mathHook, pictureHook(renderer, pictures))
+	renderer.Opts.RenderNodeHook = chainHooks(hooks...)
+
 	return markdown.Render(doc, renderer)
 }
+
+// function rewriteLinks points relative links to markdown files
+// (ex. [setup](./setup.md#install)) to the route the file is built to,
+// keeping any fragment.
+func (m MD) rewriteLinks(doc ast.Node) []Warning {
+	warnings := []Warning{}
+	if m.Resolver == nil {
+		return warnings
+	}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		link, ok := node.(*ast.Link)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		dest := string(link.Destination)
+		u, err := url.Parse(dest)
+		if err != nil || u.IsAbs() || u.Host != "" || u.Path == "" ||
+			strings.HasPrefix(u.Path, "/") || !strings.EqualFold(filepath.Ext(u.Path), ".md") {
+			return ast.GoToNext
+		}
+
+		fp := filepath.Join(filepath.Dir(m.FilePath), filepath.FromSlash(u.Path))
+		route, ok := m.Resolver(fp)
+		if !ok {
+			warnings = append(warnings, Warning{
+				FilePath: m.FilePath,
+				Message:  fmt.Sprintf("link to missing file %v", dest),
+			})
+
+			return ast.GoToNext
+		}
+
+		if u.Fragment != "" {
+			route += "#" + u.Fragment
+		}
+
+		link.Destination = []byte(route)
+		return ast.GoToNext
+	})
+
+	return warnings
+}
+
+// function processImages adds the dimensions & responsive variants
+// returned by the image resolver to each image. Images with alternative
+// formats are returned so that they can be wrapped in a <picture>.
+func (m MD) processImages(doc ast.Node) (map[*ast.Image][]ImageSource, []Warning) {
+	pictures := map[*ast.Image][]ImageSource{}
+	warnings := []Warning{}
+	if m.Images == nil {
+		return pictures, warnings
+	}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		img, ok := node.(*ast.Image)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		processed, err := m.Images(string(img.Destination), m.FilePath)
+		if err != nil {
+			warnings = append(warnings, Warning{FilePath: m.FilePath, Message: err.Error()})
+			return ast.GoToNext
+		}
+
+		if processed == nil {
+			return ast.GoToNext
+		}
+
+		img.Destination = []byte(processed.Src)
+		if img.Attribute == nil {
+			img.Attribute = &ast.Attribute{}
+		}
+
+		if img.Attribute.Attrs == nil {
+			img.Attribute.Attrs = map[string][]byte{}
+		}
+
+		// Attributes set on the image (ex. by an extension) are kept, and
+		// a width or height keeps the image from being sized
+		attrs := img.Attribute.Attrs
+		_, hasWidth := attrs["width"]
+		_, hasHeight := attrs["height"]
+		if processed.Width > 0 && processed.Height > 0 && !hasWidth && !hasHeight {
+			attrs["width"] = []byte(fmt.Sprint(processed.Width))
+			attrs["height"] = []byte(fmt.Sprint(processed.Height))
+		}
+
+		if _, ok := attrs["srcset"]; !ok && processed.Srcset != "" {
+			attrs["srcset"] = []byte(template.HTMLEscapeString(processed.Srcset))
+			if processed.Sizes != "" {
+				attrs["sizes"] = []byte(template.HTMLEscapeString(processed.Sizes))
+			}
+		}
+
+		if len(processed.Sources) > 0 {
+			pictures[img] = processed.Sources
+		}
+
+		return ast.GoToNext
+	})
+
+	return pictures, warnings
+}
+
+// function chainHooks calls each render hook in turn until one of them
+// renders the node
+func chainHooks(hooks ...html.RenderNodeFunc) html.RenderNodeFunc {
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		for _, hook := range hooks {
+			if status, ok := hook(w, node, entering); ok {
+				return status, ok
+			}
+		}
+
+		return ast.GoToNext, false
+	}
+}
+
+// function pictureHook wraps images with alternative formats in a
+// <picture> element, leaving the <img> itself to the renderer
+func pictureHook(r *html.Renderer, pictures map[*ast.Image][]ImageSource) html.RenderNodeFunc {
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		img, ok := node.(*ast.Image)
+		if !ok {
+			return ast.GoToNext, false
+		}
+
+		sources, ok := pictures[img]
+		if !ok {
+			return ast.GoToNext, false
+		}
+
+		if !entering {
+			r.Image(w, img, false)
+			io.WriteString(w, "</picture>")
+			return ast.GoToNext, true
+		}
+
+		io.WriteString(w, "<picture>")
+		for _, s := range sources {
+			fmt.Fprintf(w, `<source type="%v" srcset="%v"`, template.HTMLEscapeString(s.Type), template.HTMLEscapeString(s.Srcset))
+			if sizes, ok := img.Attribute.Attrs["sizes"]; ok {
+				fmt.Fprintf(w, ` sizes="%s"`, sizes)
+			}
+
+			io.WriteString(w, " />")
+		}
+
+		r.Image(w, img, true)
+		return ast.GoToNext, true
+	}
+}
@@ -2,7 +2,17 @@ package md
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
 )
 
 type testCase struct {
@@ -15,12 +25,16 @@ func TestFrontmatter(t *testing.T) {
 		Title:  "Some Title",
 		Draft:  true,
 		Layout: "base",
+		Date:   time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC),
+		Tags:   []string{"go", "docs"},
 	}
 
 	toml_fm := []byte(`+++
 title = "Some Title"
 draft = true
 layout = "base"
+date = 2025-01-05
+tags = ["go", "docs"]
 +++
 `)
 
@@ -28,6 +42,8 @@ layout = "base"
 title: "Some Title"
 draft: true
 layout: "base"
+date: 2025-01-05
+tags: ["go", "docs"]
 ---
 	`)
 
@@ -60,8 +76,693 @@ layout: "base"
 				if got.Layout != want.Layout {
 					t.Errorf("got %v, want %v", got.Layout, want.Layout)
 				}
+
+				if got.Date.Format(time.DateOnly) != want.Date.Format(time.DateOnly) {
+					t.Errorf("got %v, want %v", got.Date, want.Date)
+				}
+
+				if !slices.Equal(got.Tags, want.Tags) {
+					t.Errorf("got %v, want %v", got.Tags, want.Tags)
+				}
 			})
 		}
 
 	})
 }
+
+func TestPreview(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"published.md": "---\ntitle: Published\nexpiryDate: 2025-12-01\n---\n",
+		"draft.md":     "---\ntitle: Draft\ndraft: true\n---\n",
+		"future.md":    "+++\ntitle = \"Future\"\npublishDate = 2025-07-01\n+++\n",
+		"expired.md":   "---\ntitle: Expired\npublishDate: 2025-01-01\nexpiryDate: 2025-05-01\n---\n",
+	}
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("unable to write %v %v", name, err.Error())
+		}
+	}
+
+	read := func(p Preview) []string {
+		p.Now = now
+		mdFiles, err := ReadContentDirectory(dir, "", p)
+		if err != nil {
+			t.Fatalf("unable to read content %v", err.Error())
+		}
+
+		titles := []string{}
+		for _, m := range mdFiles {
+			titles = append(titles, m.Frontmatter.Title)
+		}
+
+		slices.Sort(titles)
+		return titles
+	}
+
+	t.Run("unpublished pages are skipped", func(t *testing.T) {
+		if got := read(Preview{}); !slices.Equal(got, []string{"Published"}) {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("drafts & future pages can be previewed", func(t *testing.T) {
+		if got := read(Preview{Drafts: true}); !slices.Equal(got, []string{"Draft", "Published"}) {
+			t.Errorf("got %v", got)
+		}
+
+		if got := read(Preview{Drafts: true, Future: true}); !slices.Equal(got, []string{"Draft", "Future", "Published"}) {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("Unpublished reports why a page isn't published", func(t *testing.T) {
+		for name, want := range map[string][]string{"published.md": {}, "draft.md": {Draft}, "future.md": {Scheduled}, "expired.md": {Expired}} {
+			fm, _, _ := SplitFrontmatter([]byte(files[name]))
+			if got := fm.Unpublished(now); !slices.Equal(got, want) {
+				t.Errorf("%v: got %q, want %q", name, got, want)
+			}
+		}
+
+		var fm *Frontmatter
+		if len(fm.Unpublished(now)) != 0 {
+			t.Error("files without frontmatter are published")
+		}
+	})
+
+	t.Run("Unpublished reports every reason that applies", func(t *testing.T) {
+		fm, _, _ := SplitFrontmatter([]byte("---\ntitle: Scheduled Draft\ndraft: true\npublishDate: 2025-07-01\n---\n"))
+		reasons := fm.Unpublished(now)
+		if want := []string{Draft, Scheduled}; !slices.Equal(reasons, want) {
+			t.Errorf("got %q, want %q", reasons, want)
+		}
+
+		if (Preview{Drafts: true}).Includes(reasons) {
+			t.Error("a scheduled draft should need --future as well as --drafts")
+		}
+
+		if !(Preview{Drafts: true, Future: true}).Includes(reasons) {
+			t.Error("a scheduled draft should be previewed with --drafts & --future")
+		}
+	})
+}
+
+func TestLinks(t *testing.T) {
+	routes := map[string]string{
+		"docs/guides/setup.md": "/guides/setup",
+		"docs/README.md":       "/",
+	}
+
+	m := MD{
+		FilePath: "docs/guides/install.md",
+		Content: []byte(`[setup](./setup.md#building) [home](../README.md) [missing](./nope.md)
+[site](https://example.com/page.md) [anchor](#top) [root](/about)`),
+		Resolver: func(fp string) (string, bool) {
+			route, ok := routes[fp]
+			return route, ok
+		},
+	}
+
+	data, warnings := m.Render()
+	html := string(data)
+
+	t.Run("rewrites relative markdown links to routes", func(t *testing.T) {
+		for _, want := range []string{`href="/guides/setup#building"`, `href="/"`} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+	})
+
+	t.Run("leaves other links untouched", func(t *testing.T) {
+		for _, want := range []string{`href="https://example.com/page.md"`, `href="#top"`, `href="/about"`, `href="./nope.md"`} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+	})
+
+	t.Run("warns about links to missing files", func(t *testing.T) {
+		if len(warnings) != 1 {
+			t.Fatalf("there should be 1 warning, got %v", warnings)
+		}
+
+		if !strings.Contains(warnings[0].Error(), m.FilePath) {
+			t.Errorf("warning %v should name the source file", warnings[0].Error())
+		}
+	})
+}
+
+func TestImages(t *testing.T) {
+	m := MD{
+		FilePath: "docs/guide.md",
+		Content:  []byte("![diagram](./diagram.png) ![photo](./photo.jpg) ![remote](https://example.com/x.png) ![missing](./nope.png)"),
+		Images: func(dest, fp string) (*Image, error) {
+			switch dest {
+			case "./diagram.png":
+				return &Image{Src: "/assets/images/diagram.png", Width: 800, Height: 600, Srcset: "/assets/images/diagram-480w.png 480w", Sizes: "100vw"}, nil
+			case "./photo.jpg":
+				return &Image{
+					Src: "/assets/images/photo.jpg", Width: 100, Height: 50,
+					Sources: []ImageSource{{Type: "image/webp", Srcset: "/assets/images/photo.webp 100w"}},
+				}, nil
+			case "./nope.png":
+				return nil, fmt.Errorf("image %v not found", dest)
+			default:
+				return nil, nil
+			}
+		},
+	}
+
+	data, warnings := m.Render()
+	html := string(data)
+
+	t.Run("images are lazy loaded with their dimensions & variants", func(t *testing.T) {
+		for _, want := range []string{
+			`src="/assets/images/diagram.png"`, `width="800"`, `height="600"`,
+			`srcset="/assets/images/diagram-480w.png 480w"`, `sizes="100vw"`, `loading="lazy"`,
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+	})
+
+	t.Run("alternative formats are wrapped in a picture", func(t *testing.T) {
+		want := `<picture><source type="image/webp" srcset="/assets/images/photo.webp 100w" /><img`
+		if !strings.Contains(html, want) || !strings.Contains(html, "</picture>") {
+			t.Errorf("%v should contain %v", html, want)
+		}
+
+		if strings.Count(html, "<picture>") != 1 {
+			t.Errorf("only images with sources should be wrapped %v", html)
+		}
+	})
+
+	t.Run("unresolved images are untouched & errors are warnings", func(t *testing.T) {
+		if !strings.Contains(html, `src="https://example.com/x.png"`) {
+			t.Errorf("%v should contain the remote image", html)
+		}
+
+		if len(warnings) != 1 {
+			t.Errorf("there should be 1 warning, got %v", warnings)
+		}
+	})
+
+	t.Run("attributes already set on an image are kept", func(t *testing.T) {
+		doc := m.parse([]byte("![diagram](./diagram.png)"))
+		var img *ast.Image
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			if i, ok := node.(*ast.Image); ok {
+				img = i
+			}
+
+			return ast.GoToNext
+		})
+
+		img.Attribute = &ast.Attribute{
+			Classes: [][]byte{[]byte("wide")},
+			Attrs:   map[string][]byte{"width": []byte("400")},
+		}
+
+		m.processImages(doc)
+
+		attrs := img.Attribute.Attrs
+		if len(img.Attribute.Classes) != 1 || string(attrs["width"]) != "400" {
+			t.Errorf("the class & width should be kept %+v", img.Attribute)
+		}
+
+		if _, ok := attrs["height"]; ok {
+			t.Errorf("the height shouldn't be set when the width is %+v", attrs)
+		}
+
+		if _, ok := attrs["srcset"]; !ok {
+			t.Errorf("the srcset should be added %+v", attrs)
+		}
+	})
+}
+
+func TestShortcodes(t *testing.T) {
+	render := func(m MD) (string, []Warning) {
+		data, warnings := m.Render()
+		return string(data), warnings
+	}
+
+	t.Run("built-in shortcodes render their inner markdown", func(t *testing.T) {
+		html, warnings := render(MD{FilePath: "page.md", Content: []byte(
+			"{{< note type=\"warning\" >}}\nDon't **panic**.\n{{< /note >}}\n",
+		)})
+
+		for _, want := range []string{
+			`<aside class="callout callout-warning" role="note">`,
+			`<p class="callout-title">Warning</p>`,
+			"<p>Don&rsquo;t <strong>panic</strong>.</p>",
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		if strings.Contains(html, "<p><aside") || len(warnings) > 0 {
+			t.Errorf("the shortcode should replace its paragraph %v %v", html, warnings)
+		}
+	})
+
+	t.Run("nested shortcodes know their parent & position", func(t *testing.T) {
+		html, _ := render(MD{FilePath: "page.md", Content: []byte(
+			"{{< tabs >}}\n{{< tab \"Go\" >}}\ngo run .\n{{< /tab >}}\n\n{{< tab title='Shell' >}}\nmake\n{{< /tab >}}\n{{< /tabs >}}\n",
+		)})
+
+		if strings.Count(html, `name="tabs-1"`) != 2 || strings.Count(html, "checked") != 1 {
+			t.Errorf("tabs should be grouped by their parent %v", html)
+		}
+
+		for _, want := range []string{">Go</label>", ">Shell</label>", "<p>go run .</p>", "<p>make</p>"} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		if strings.Contains(html, "documango-shortcode") {
+			t.Errorf("all placeholders should be replaced %v", html)
+		}
+	})
+
+	t.Run("templates in the shortcode dir override the defaults", func(t *testing.T) {
+		dir := t.TempDir()
+		templ := `<span class="{{ .Get "type" }}">{{ .Get 0 }}{{ .Inner }}</span>`
+		if err := os.WriteFile(filepath.Join(dir, "note.html"), []byte(templ), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		html, _ := render(MD{FilePath: "page.md", ShortcodeDir: dir, Content: []byte(
+			`Inline {{< note v1.2 type=tip />}} text`,
+		)})
+
+		if want := `<p>Inline <span class="tip">v1.2</span> text</p>`; !strings.Contains(html, want) {
+			t.Errorf("%v should contain %v", html, want)
+		}
+	})
+
+	t.Run("code blocks & escaped shortcodes are left as text", func(t *testing.T) {
+		html, warnings := render(MD{FilePath: "page.md", Content: []byte(
+			"Write {{</* note */>}} to add a note.\n\n```md\n{{< note >}}\n```\n",
+		)})
+
+		if strings.Count(html, "{{&lt; note &gt;}}") != 2 || strings.Contains(html, "callout") {
+			t.Errorf("shortcodes should not be rendered %v", html)
+		}
+
+		if len(warnings) > 0 {
+			t.Errorf("there should be no warnings, got %v", warnings)
+		}
+	})
+
+	t.Run("code spans & indented code are left as text", func(t *testing.T) {
+		html, warnings := render(MD{FilePath: "page.md", Content: []byte(
+			"Use `{{< note >}}` inline, or ``{{< note >}}` ``.\n\n    {{< note >}}\n\n- item\n\n    {{< note />}}\n",
+		)})
+
+		if n := strings.Count(html, "<code>{{&lt; note &gt;}}"); n != 3 {
+			t.Errorf("shortcodes in code should not be rendered, got %v %v", n, html)
+		}
+
+		if strings.Count(html, "<aside") != 1 || strings.Contains(html, "documango-shortcode") {
+			t.Errorf("the shortcode in the list item should be rendered %v", html)
+		}
+
+		if len(warnings) > 0 {
+			t.Errorf("there should be no warnings, got %v", warnings)
+		}
+	})
+
+	t.Run("unknown shortcodes are untouched & reported", func(t *testing.T) {
+		html, warnings := render(MD{FilePath: "page.md", Content: []byte(
+			"{{< missing >}}text{{< /missing >}}\n\n{{< /note >}}",
+		)})
+
+		if !strings.Contains(html, "{{&lt; missing &gt;}}text{{&lt; /missing &gt;}}") {
+			t.Errorf("unknown shortcodes should be kept %v", html)
+		}
+
+		if len(warnings) != 2 {
+			t.Errorf("there should be 2 warnings, got %v", warnings)
+		}
+	})
+}
+
+func TestCallouts(t *testing.T) {
+	render := func(content string) string {
+		data, _ := MD{FilePath: "page.md", Content: []byte(content)}.Render()
+		return string(data)
+	}
+
+	t.Run("GitHub alerts are rendered as callouts", func(t *testing.T) {
+		html := render("> [!WARNING]\n> Back up **first**.\n>\n> - then upgrade\n\n> a quote\n")
+
+		for _, want := range []string{
+			`<aside class="callout callout-warning" role="note">`,
+			`<p class="callout-title">Warning</p>`,
+			"<strong>first</strong>", "<li>then upgrade</li>",
+			"<blockquote>\n<p>a quote</p>\n</blockquote>",
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		if strings.Contains(html, "[!WARNING]") {
+			t.Errorf("the alert marker should be removed %v", html)
+		}
+	})
+
+	t.Run("containers are rendered as callouts & can be nested", func(t *testing.T) {
+		html := render(":::: tip Before you start\nOuter\n\n::: caution\nInner\n:::\n::::\n\nAfter\n")
+
+		for _, want := range []string{
+			`<aside class="callout callout-tip" role="note">`,
+			`<p class="callout-title">Before you start</p>`,
+			`<aside class="callout callout-caution" role="note">`,
+			"<p>Inner</p>\n</aside>",
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		if strings.Count(html, "</aside>") != 2 || strings.Contains(html, ":::") {
+			t.Errorf("every container should be closed %v", html)
+		}
+
+		if i := strings.Index(html, "<p>After</p>"); i < strings.LastIndex(html, "</aside>") {
+			t.Errorf("content after the container should be outside of it %v", html)
+		}
+	})
+
+	t.Run("callouts in code blocks are left as is", func(t *testing.T) {
+		html := render("```md\n> [!NOTE]\n::: tip\n:::\n```\n")
+
+		if strings.Contains(html, "callout") {
+			t.Errorf("callouts in code should not be rendered %v", html)
+		}
+	})
+}
+
+func TestMath(t *testing.T) {
+	render := func(content string) string {
+		data, _ := MD{FilePath: "page.md", Content: []byte(content)}.Render()
+		return string(data)
+	}
+
+	t.Run("inline math is typeset with \\( \\)", func(t *testing.T) {
+		html := render("Where $a_i < b$ and $$\\sum x$$ hold.")
+
+		for _, want := range []string{
+			`<span class="math inline">\(a_i &lt; b\)</span>`,
+			`<span class="math display">\[\sum x\]</span>`,
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+	})
+
+	t.Run("block math is typeset with \\[ \\]", func(t *testing.T) {
+		html := render("$$\n\\frac{a}{b}\n$$\n")
+
+		if want := `<div class="math display">\[\frac{a}{b}\]</div>`; !strings.Contains(html, want) {
+			t.Errorf("%v should contain %v", html, want)
+		}
+	})
+
+	t.Run("prices, escapes & code aren't math", func(t *testing.T) {
+		html := render("It costs $5 and $10, or \\$20 for `$x$`.")
+
+		if strings.Contains(html, "math") {
+			t.Errorf("there should be no math in %v", html)
+		}
+
+		if !strings.Contains(html, "$5 and $10, or $20") {
+			t.Errorf("dollar signs should be kept %v", html)
+		}
+	})
+}
+
+func TestDiagrams(t *testing.T) {
+	render := func(content string) (string, []Warning) {
+		data, warnings := MD{FilePath: "page.md", Content: []byte(content)}.Render()
+		return string(data), warnings
+	}
+
+	t.Run("mermaid fences are drawn in the browser", func(t *testing.T) {
+		html, _ := render("```mermaid\ngraph TD\n  A --> B\n```\n")
+
+		if want := "<pre class=\"mermaid\">graph TD\n  A --&gt; B\n</pre>"; !strings.Contains(html, want) {
+			t.Errorf("%v should contain %v", html, want)
+		}
+
+		if !HasMermaid([]byte(html)) || HasMermaid([]byte("<pre><code>mermaid</code></pre>")) {
+			t.Error("only pages with mermaid diagrams need the script")
+		}
+	})
+
+	t.Run("registered renderers inline their output", func(t *testing.T) {
+		RegisterDiagram("echo", Executable("cat"))
+		RegisterDiagram("broken", func([]byte) ([]byte, error) { return nil, fmt.Errorf("syntax error") })
+		t.Cleanup(func() {
+			RegisterDiagram("echo", nil)
+			RegisterDiagram("broken", nil)
+		})
+
+		html, warnings := render("```echo\n<?xml version=\"1.0\"?>\n<svg></svg>\n```\n\n```broken\nx\n```\n")
+
+		if want := `<figure class="diagram diagram-echo"><svg></svg></figure>`; !strings.Contains(html, want) {
+			t.Errorf("%v should contain %v", html, want)
+		}
+
+		if !strings.Contains(html, `<code class="language-broken">x`) || len(warnings) != 1 {
+			t.Errorf("failed diagrams should be rendered as code & reported %v %v", html, warnings)
+		}
+	})
+
+	t.Run("executables only render a diagram once", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+		RegisterDiagram("counted", Executable("sh", "-c", fmt.Sprintf("echo >> %v && cat", runs)))
+		t.Cleanup(func() { RegisterDiagram("counted", nil) })
+
+		for _, source := range []string{"<svg>a</svg>", "<svg>a</svg>", "<svg>b</svg>"} {
+			if html, _ := render("```counted\n" + source + "\n```\n"); !strings.Contains(html, source) {
+				t.Errorf("%v should contain %v", html, source)
+			}
+		}
+
+		if data, _ := os.ReadFile(runs); strings.Count(string(data), "\n") != 2 {
+			t.Errorf("unchanged diagrams should be cached, rendered %v times", strings.Count(string(data), "\n"))
+		}
+	})
+
+	t.Run("missing executables fall back to code", func(t *testing.T) {
+		RegisterDiagram("missing", Executable("documango-missing-executable"))
+		t.Cleanup(func() { RegisterDiagram("missing", nil) })
+
+		html, warnings := render("```missing\ndigraph {}\n```\n")
+
+		if !strings.Contains(html, `<code class="language-missing">`) || len(warnings) > 0 {
+			t.Errorf("the fence should be rendered as code without warnings %v %v", html, warnings)
+		}
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	content := []byte("## Setup\n\n[docs](https://example.com)\n\n| a | b |\n| - | - |\n| 1 | 2 |\n")
+
+	t.Run("options replace the default extensions & flags", func(t *testing.T) {
+		opts, err := ParseOptions([]string{"common", "-tables"}, []string{"common", "noopener_links"})
+		if err != nil {
+			t.Fatalf("unable to parse options %v", err.Error())
+		}
+
+		data, _ := MD{FilePath: "page.md", Content: content, Options: &opts}.Render()
+		html := string(data)
+
+		if strings.Contains(html, `target="_blank"`) || !strings.Contains(html, `rel="noopener"`) {
+			t.Errorf("links should use the configured flags %v", html)
+		}
+
+		if strings.Contains(html, "<table>") {
+			t.Errorf("tables should be disabled %v", html)
+		}
+
+		if _, err := ParseOptions([]string{"tabels"}, nil); err == nil || !strings.Contains(err.Error(), "tabels") {
+			t.Errorf("unknown names should be an error, got %v", err)
+		}
+	})
+
+	t.Run("removals alone apply to the defaults", func(t *testing.T) {
+		opts, err := ParseOptions([]string{"-tables"}, []string{"-href_target_blank"})
+		if err != nil {
+			t.Fatalf("unable to parse options %v", err.Error())
+		}
+
+		if want := DefaultOptions.Extensions &^ parser.Tables; opts.Extensions != want {
+			t.Errorf("extensions should be %v, got %v", want, opts.Extensions)
+		}
+
+		if want := DefaultOptions.Flags &^ html.HrefTargetBlank; opts.Flags != want {
+			t.Errorf("flags should be %v, got %v", want, opts.Flags)
+		}
+	})
+
+	t.Run("extensions transform the document & hook into rendering", func(t *testing.T) {
+		ext := Extension{
+			Name: "test",
+			Transform: func(m MD, doc ast.Node) []Warning {
+				return []Warning{{FilePath: m.FilePath, Message: "transformed"}}
+			},
+			Heading: func(r *html.Renderer, w io.Writer, node *ast.Heading, entering bool) bool {
+				r.Heading(w, node, entering)
+				if entering {
+					fmt.Fprintf(w, `<a href="#%v">#</a>`, node.HeadingID)
+				}
+
+				return true
+			},
+			Link: func(r *html.Renderer, w io.Writer, node *ast.Link, entering bool) bool {
+				if entering {
+					return false
+				}
+
+				r.Link(w, node, entering)
+				io.WriteString(w, `<span class="external"></span>`)
+				return true
+			},
+			Table: func(r *html.Renderer, w io.Writer, node *ast.Table, entering bool) bool {
+				r.OutOneOfCr(w, entering, `<div class="table"><table>`, "</table></div>")
+				return true
+			},
+		}
+
+		Register(ext)
+		t.Cleanup(func() { Unregister("test") })
+
+		data, warnings := MD{FilePath: "page.md", Content: content}.Render()
+		html := string(data)
+
+		for _, want := range []string{
+			`<h2 id="setup"><a href="#setup">#</a>Setup</h2>`,
+			`docs</a><span class="external"></span>`,
+			`<div class="table"><table>`, "</table></div>",
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		if len(warnings) != 1 || warnings[0].Message != "transformed" {
+			t.Errorf("the transform should report a warning, got %v", warnings)
+		}
+
+		Unregister("test")
+		if data, _ := (MD{Content: content}).Render(); strings.Contains(string(data), "external") {
+			t.Errorf("unregistered extensions shouldn't be used %s", data)
+		}
+	})
+}
+
+func TestHeadings(t *testing.T) {
+	content := []byte("# Title\n\n## Getting Started {#start}\n\n### Install\n\n##### Notes\n\n## Install {#install}\n")
+
+	t.Run("custom ids & permalinks on h2-h4", func(t *testing.T) {
+		data, _ := MD{FilePath: "page.md", Content: content}.Render()
+		html := string(data)
+
+		for _, want := range []string{
+			`<h2 id="start">Getting Started <a class="heading-anchor" href="#start" aria-label="Link to Getting Started">¶</a></h2>`,
+			`<h3 id="install">Install <a class="heading-anchor" href="#install"`,
+			`<h1 id="title">Title</h1>`, `<h5 id="notes">Notes</h5>`,
+		} {
+			if !strings.Contains(html, want) {
+				t.Errorf("%v should contain %v", html, want)
+			}
+		}
+
+		opts := DefaultOptions
+		opts.Anchors = false
+		if data, _ := (MD{Content: content, Options: &opts}).Render(); strings.Contains(string(data), "¶") {
+			t.Errorf("anchors should be disabled %s", data)
+		}
+	})
+
+	t.Run("duplicate ids are reported", func(t *testing.T) {
+		_, warnings := MD{FilePath: "page.md", Content: content}.Render()
+
+		if len(warnings) != 1 || !strings.Contains(warnings[0].Message, `duplicate heading id #install ("Install" & "Install")`) {
+			t.Errorf("there should be a duplicate id warning, got %v", warnings)
+		}
+
+		_, warnings = MD{FilePath: "page.md", Content: []byte("## Example\n\n## Example\n")}.Render()
+		if len(warnings) > 0 {
+			t.Errorf("generated ids are numbered without warnings, got %v", warnings)
+		}
+	})
+
+	t.Run("ids in callouts & shortcodes are checked against the page", func(t *testing.T) {
+		_, warnings := MD{FilePath: "page.md", Content: []byte(
+			"## Setup\n\n> [!NOTE]\n> ## Setup\n\n{{< note >}}\n## Setup\n{{< /note >}}\n",
+		)}.Render()
+
+		if len(warnings) != 2 {
+			t.Fatalf("there should be 2 duplicate id warnings, got %v", warnings)
+		}
+
+		for _, w := range warnings {
+			if !strings.Contains(w.Message, "duplicate heading id #setup") {
+				t.Errorf("%v should report #setup", w.Message)
+			}
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	stats := func(content string) Stats {
+		return MD{FilePath: "page.md", Content: []byte(content)}.Stats()
+	}
+
+	t.Run("words are counted in text but not code", func(t *testing.T) {
+		s := stats("# A title\n\nSome *emphasized* `code` and a [link](./x.md).\n\n```go\nfunc main() {}\n```\n\n{{< note >}}Inner words{{< /note >}}\n")
+
+		if s.Words != 10 || s.ReadingTime != 1 {
+			t.Errorf("got %+v, want 10 words & 1 minute", s)
+		}
+
+		if s.Summary != "A title Some emphasized code and a link. Inner words" || s.Truncated {
+			t.Errorf("short pages are their own summary, got %q", s.Summary)
+		}
+
+		if s := stats(strings.Repeat("word ", 401)); s.ReadingTime != 3 {
+			t.Errorf("reading time should be rounded up, got %v", s.ReadingTime)
+		}
+
+		if s := stats(""); s.Words != 0 || s.ReadingTime != 0 || s.Summary != "" {
+			t.Errorf("empty pages have no stats, got %+v", s)
+		}
+	})
+
+	t.Run("the summary ends at the more marker", func(t *testing.T) {
+		s := stats("The **intro**.\n\n```md\n<!--more-->\n```\n\nStill intro.\n\n<!--more-->\n\nThe rest.")
+
+		if s.Summary != "The intro. Still intro." || !s.Truncated {
+			t.Errorf("got %+v", s)
+		}
+	})
+
+	t.Run("long pages are summarized by their first words", func(t *testing.T) {
+		s := stats(strings.Repeat("lorem ipsum ", SummaryWords))
+
+		if got := len(strings.Fields(s.Summary)); got != SummaryWords || !strings.HasSuffix(s.Summary, "…") || !s.Truncated {
+			t.Errorf("got %v words in %q", got, s.Summary)
+		}
+	})
+}
@@ -0,0 +1,578 @@
+package md
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//go:embed shortcodes
+var DefaultShortcodes embed.FS
+
+// shortcodeFuncs are available to shortcode templates in addition to
+// the builtin functions
+var shortcodeFuncs = template.FuncMap{
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+	"add": func(a, b int) int { return a + b },
+}
+
+var (
+	shortcodeName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	fencePattern  = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	indentPattern = regexp.MustCompile(`^( {4}|\t)`)
+	listPattern   = regexp.MustCompile(`^ {0,3}([-*+]|\d+[.)])([ \t]|$)`)
+)
+
+// type Shortcode is the data passed to a shortcode template. Shortcodes
+// are written as {{< name key="value" >}}inner{{< /name >}} or, without
+// inner content, {{< name key="value" >}}. Positional arguments (ex.
+// {{< youtube abc123 >}}) are available in Args.
+type Shortcode struct {
+	Name   string
+	Params map[string]string
+	Args   []string
+	// Inner is the content between the opening & closing tags,
+	// rendered as markdown
+	Inner template.HTML
+	// Parent is the shortcode this one is nested in, if any
+	Parent *Shortcode
+	// Ordinal is the position of the shortcode in its parent,
+	// starting at 0
+	Ordinal int
+	// ID is unique to the shortcode in the page, ex. for the ids of
+	// form elements
+	ID string
+	// Page is the frontmatter of the page the shortcode is in
+	Page *Frontmatter
+}
+
+// function Get returns the named parameter for a string key, or the
+// positional argument for an int. Missing values are empty.
+func (s Shortcode) Get(key any) string {
+	switch k := key.(type) {
+	case string:
+		return s.Params[k]
+	case int:
+		if k >= 0 && k < len(s.Args) {
+			return s.Args[k]
+		}
+	}
+
+	return ""
+}
+
+// type shortcodeRenderer expands the shortcodes of a single render of
+// a file. Templates are read from the shortcode dir, falling back to
+// DefaultShortcodes, & cached for the render.
+type shortcodeRenderer struct {
+	m         MD
+	templates map[string]*template.Template
+	rendered  map[string][]byte
+	warnings  []Warning
+	count     int
+	// headings are the heading ids of the page & its fragments, so that
+	// ids are checked across them
+	headings map[string]string
+}
+
+// type shortcodeTag is an opening, closing or self-closing tag
+type shortcodeTag struct {
+	start, end int
+	name       string
+	args       string
+	closing    bool
+	selfClose  bool
+}
+
+func newShortcodeRenderer(m MD) *shortcodeRenderer {
+	return &shortcodeRenderer{
+		m:         m,
+		templates: map[string]*template.Template{},
+		rendered:  map[string][]byte{},
+		headings:  map[string]string{},
+	}
+}
+
+// function placeholder is a comment that survives markdown rendering
+// and is replaced by the shortcode's HTML afterwards
+func placeholder(n int) string {
+	return fmt.Sprintf("<!--documango-shortcode-%v-->", n)
+}
+
+// function expand replaces the shortcodes in content with placeholders
+// and renders them. Shortcodes in code blocks & spans are left as is
+// and {{</* name */>}} is written as {{< name >}}.
+func (r *shortcodeRenderer) expand(content []byte, parent *Shortcode) []byte {
+	out := bytes.Buffer{}
+	fences := codeRanges(content)
+	ordinal := 0
+	i := 0
+
+	for i < len(content) {
+		tag, ok := nextShortcode(content, i, fences)
+		if !ok {
+			break
+		}
+
+		out.Write(content[i:tag.start])
+
+		// Escaped shortcodes are written literally
+		if strings.HasPrefix(tag.args, "/*") || (tag.closing && strings.HasPrefix(tag.name, "*")) {
+			literal := string(content[tag.start:tag.end])
+			literal = strings.Replace(literal, "{{</*", "{{<", 1)
+			literal = strings.Replace(literal, "*/>}}", ">}}", 1)
+			out.WriteString(literal)
+			i = tag.end
+			continue
+		}
+
+		if tag.closing {
+			r.warn("unexpected closing shortcode {{< /%v >}}", tag.name)
+			out.Write(content[tag.start:tag.end])
+			i = tag.end
+			continue
+		}
+
+		sc := &Shortcode{
+			Name:    tag.name,
+			Parent:  parent,
+			Ordinal: ordinal,
+			Page:    r.m.Frontmatter,
+		}
+		sc.Params, sc.Args = parseShortcodeArgs(tag.args)
+
+		end := tag.end
+		inner := []byte(nil)
+		if !tag.selfClose {
+			if closeTag, ok := findClosingShortcode(content, tag, fences); ok {
+				inner = content[tag.end:closeTag.start]
+				end = closeTag.end
+			}
+		}
+
+		html, ok := r.render(sc, inner)
+		if !ok {
+			out.Write(content[tag.start:end])
+			i = end
+			continue
+		}
+
+		r.count++
+		key := placeholder(r.count)
+		r.rendered[key] = html
+		out.WriteString(key)
+
+		ordinal++
+		i = end
+	}
+
+	out.Write(content[i:])
+	return out.Bytes()
+}
+
+// function render executes the template of sc, with inner rendered as
+// markdown (including its own shortcodes)
+func (r *shortcodeRenderer) render(sc *Shortcode, inner []byte) ([]byte, bool) {
+	templ, err := r.template(sc.Name)
+	if err != nil {
+		r.warn("%v", err.Error())
+		return nil, false
+	}
+
+	sc.ID = fmt.Sprintf("%v-%v", sc.Name, r.count+1)
+	if inner != nil {
+		// Nested shortcodes are numbered after their parent
+		r.count++
+		sc.Inner = template.HTML(r.markdown(bytes.TrimSpace(inner), sc))
+	}
+
+	b := bytes.Buffer{}
+	if err := templ.Execute(&b, sc); err != nil {
+		r.warn("unable to render shortcode %v: %v", sc.Name, err.Error())
+		return nil, false
+	}
+
+	return b.Bytes(), true
+}
+
+// function markdown renders a fragment of the file, ex. the inner
+// content of a shortcode
+func (r *shortcodeRenderer) markdown(content []byte, parent *Shortcode) []byte {
+	doc := r.m.parse(r.expand(content, parent))
+	pictures, warnings := r.m.transform(doc)
+	r.warnings = append(r.warnings, warnings...)
+	r.warnings = append(r.warnings, r.m.checkHeadingIDs(doc, r.headings)...)
+
+	return bytes.TrimSpace(r.m.render(doc, pictures))
+}
+
+// function replace swaps the placeholders in html for the rendered
+// shortcodes. Placeholders alone in a paragraph replace it.
+func (r *shortcodeRenderer) replace(html []byte) []byte {
+	if len(r.rendered) == 0 {
+		return html
+	}
+
+	pairs := make([]string, 0, len(r.rendered)*4)
+	for key, rendered := range r.rendered {
+		pairs = append(pairs, "<p>"+key+"</p>", string(rendered), key, string(rendered))
+	}
+
+	// Nested shortcodes are already part of their parent's HTML, so
+	// replace until there's nothing left
+	replacer := strings.NewReplacer(pairs...)
+	out := string(html)
+	for i := 0; i < 10 && strings.Contains(out, "<!--documango-shortcode-"); i++ {
+		out = replacer.Replace(out)
+	}
+
+	return []byte(out)
+}
+
+// function template reads the template of a shortcode from the shortcode
+// dir, or the defaults
+func (r *shortcodeRenderer) template(name string) (*template.Template, error) {
+	if t, ok := r.templates[name]; ok {
+		return t, nil
+	}
+
+	if !shortcodeName.MatchString(name) {
+		return nil, fmt.Errorf("invalid shortcode name %q", name)
+	}
+
+	var data []byte
+	var err error = os.ErrNotExist
+	if r.m.ShortcodeDir != "" {
+		data, err = os.ReadFile(filepath.Join(r.m.ShortcodeDir, name+".html"))
+	}
+
+	if os.IsNotExist(err) {
+		data, err = DefaultShortcodes.ReadFile("shortcodes/" + name + ".html")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unknown shortcode %v", name)
+	}
+
+	t, err := template.New(name).Funcs(shortcodeFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse shortcode %v %w", name, err)
+	}
+
+	r.templates[name] = t
+	return t, nil
+}
+
+func (r *shortcodeRenderer) warn(format string, args ...any) {
+	r.warnings = append(r.warnings, Warning{FilePath: r.m.FilePath, Message: fmt.Sprintf(format, args...)})
+}
+
+// function nextShortcode finds the next tag at or after i that isn't in
+// one of the code ranges in fences
+func nextShortcode(content []byte, i int, fences [][2]int) (shortcodeTag, bool) {
+	for {
+		start := bytes.Index(content[i:], []byte("{{<"))
+		if start < 0 {
+			return shortcodeTag{}, false
+		}
+
+		start += i
+		if end, fenced := inFence(start, fences); fenced {
+			i = end
+			continue
+		}
+
+		end := bytes.Index(content[start:], []byte(">}}"))
+		if end < 0 {
+			return shortcodeTag{}, false
+		}
+
+		end += start + len(">}}")
+		tag := shortcodeTag{start: start, end: end}
+		body := strings.TrimSpace(string(content[start+len("{{<") : end-len(">}}")]))
+
+		if strings.HasPrefix(body, "/*") {
+			tag.args = body
+			return tag, true
+		}
+
+		if strings.HasPrefix(body, "/") {
+			tag.closing = true
+			body = strings.TrimSpace(body[1:])
+		}
+
+		if strings.HasSuffix(body, "/") {
+			tag.selfClose = true
+			body = strings.TrimSpace(strings.TrimSuffix(body, "/"))
+		}
+
+		tag.name, tag.args, _ = strings.Cut(body, " ")
+		tag.args = strings.TrimSpace(tag.args)
+		return tag, true
+	}
+}
+
+// function findClosingShortcode finds the tag that closes open, skipping
+// shortcodes of the same name nested in it
+func findClosingShortcode(content []byte, open shortcodeTag, fences [][2]int) (shortcodeTag, bool) {
+	depth := 0
+	i := open.end
+	for {
+		tag, ok := nextShortcode(content, i, fences)
+		if !ok {
+			return shortcodeTag{}, false
+		}
+
+		i = tag.end
+		if tag.name != open.name || tag.selfClose {
+			continue
+		}
+
+		if !tag.closing {
+			depth++
+			continue
+		}
+
+		if depth == 0 {
+			return tag, true
+		}
+
+		depth--
+	}
+}
+
+// function parseShortcodeArgs splits the arguments of a shortcode into
+// named (key="value") & positional arguments. Values may be double
+// quoted, single quoted or bare.
+func parseShortcodeArgs(s string) (map[string]string, []string) {
+	params := map[string]string{}
+	args := []string{}
+
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		key := ""
+		if eq := strings.IndexAny(s, "= \t\"'"); eq > 0 && s[eq] == '=' {
+			key, s = s[:eq], s[eq+1:]
+		}
+
+		value := ""
+		switch {
+		case strings.HasPrefix(s, `"`):
+			end := closingQuote(s)
+			if v, err := strconv.Unquote(s[:end]); err == nil {
+				value = v
+			} else {
+				value = strings.Trim(s[:end], `"`)
+			}
+
+			s = s[end:]
+		case strings.HasPrefix(s, "'"):
+			end := strings.Index(s[1:], "'")
+			if end < 0 {
+				end = len(s) - 1
+			}
+
+			value, s = s[1:end+1], s[min(end+2, len(s)):]
+		default:
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+
+			value, s = s[:end], s[end:]
+		}
+
+		if key != "" {
+			params[key] = value
+		} else {
+			args = append(args, value)
+		}
+	}
+
+	return params, args
+}
+
+// function closingQuote is the index after the double quote closing
+// the string at the start of s, or the length of s
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return len(s)
+}
+
+// function fencedRanges returns the byte ranges of the fenced code
+// blocks in content
+func fencedRanges(content []byte) [][2]int {
+	ranges := [][2]int{}
+	fence := ""
+	start := 0
+	offset := 0
+
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		m := fencePattern.FindSubmatch(line)
+		switch {
+		case fence == "" && m != nil:
+			fence, start = string(m[1]), offset
+		case fence != "" && m != nil && strings.HasPrefix(string(m[1]), fence[:1]) && len(m[1]) >= len(fence) &&
+			strings.TrimSpace(string(line[len(m[0]):])) == "":
+			ranges = append(ranges, [2]int{start, offset + len(line)})
+			fence = ""
+		}
+
+		offset += len(line)
+	}
+
+	if fence != "" {
+		ranges = append(ranges, [2]int{start, len(content)})
+	}
+
+	return ranges
+}
+
+// function codeRanges returns the byte ranges of the code in content:
+// fenced & indented code blocks and inline code spans
+func codeRanges(content []byte) [][2]int {
+	blocks := fencedRanges(content)
+	blocks = append(blocks, indentedRanges(content, blocks)...)
+
+	return append(blocks, codeSpanRanges(content, blocks)...)
+}
+
+// function indentedRanges returns the byte ranges of the indented code
+// blocks in content, outside of fences. Indented lines only start a
+// block after a blank line, and not in a list, where they continue the
+// list item.
+func indentedRanges(content []byte, fences [][2]int) [][2]int {
+	ranges := [][2]int{}
+	start := -1
+	blank := true
+	list := false
+	offset := 0
+
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		empty := len(bytes.TrimSpace(line)) == 0
+		indented := indentPattern.Match(line)
+		_, fenced := inFence(offset, fences)
+
+		switch {
+		case start >= 0 && (empty || indented):
+		case start >= 0:
+			ranges = append(ranges, [2]int{start, offset})
+			start = -1
+			fallthrough
+		case !empty && !indented:
+			list = listPattern.Match(line) || (list && !blank)
+		case indented && blank && !list && !fenced:
+			start = offset
+		}
+
+		blank = empty
+		offset += len(line)
+	}
+
+	if start >= 0 {
+		ranges = append(ranges, [2]int{start, len(content)})
+	}
+
+	return ranges
+}
+
+// function codeSpanRanges returns the byte ranges of the inline code
+// spans in content, outside of blocks. A span is closed by a run of as
+// many backticks as opened it, in the same paragraph.
+func codeSpanRanges(content []byte, blocks [][2]int) [][2]int {
+	ranges := [][2]int{}
+	for i := 0; i < len(content); {
+		if end, ok := inFence(i, blocks); ok {
+			i = end
+			continue
+		}
+
+		if content[i] != '`' {
+			i++
+			continue
+		}
+
+		n := backticks(content, i)
+		if i > 0 && content[i-1] == '\\' {
+			i += n
+			continue
+		}
+
+		end := -1
+		for j := i + n; j < len(content) && end < 0; {
+			if _, ok := inFence(j, blocks); ok {
+				break
+			}
+
+			switch {
+			case content[j] == '`':
+				m := backticks(content, j)
+				if m == n {
+					end = j + m
+				}
+
+				j += m
+			case content[j] == '\n':
+				next, _, _ := bytes.Cut(content[j+1:], []byte("\n"))
+				if len(bytes.TrimSpace(next)) == 0 {
+					j = len(content)
+				}
+
+				j++
+			default:
+				j++
+			}
+		}
+
+		if end < 0 {
+			i += n
+			continue
+		}
+
+		ranges = append(ranges, [2]int{i, end})
+		i = end
+	}
+
+	return ranges
+}
+
+// function backticks is the length of the run of backticks at i
+func backticks(content []byte, i int) int {
+	n := 0
+	for i+n < len(content) && content[i+n] == '`' {
+		n++
+	}
+
+	return n
+}
+
+// function inFence reports whether i is in one of the code ranges in
+// fences and where the range ends
+func inFence(i int, fences [][2]int) (int, bool) {
+	for _, f := range fences {
+		if i >= f[0] && i < f[1] {
+			return f[1], true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package md
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// MoreMarker ends the summary of a page
+const MoreMarker = "<!--more-->"
+
+var (
+	// SummaryWords is the length of the summary of pages without a
+	// MoreMarker
+	SummaryWords = 70
+	// WordsPerMinute is the reading speed reading times are estimated
+	// with
+	WordsPerMinute = 200
+)
+
+var shortcodeTagPattern = regexp.MustCompile(`\{\{<.*?>\}\}`)
+
+// type Stats describes the length of a page, for listings & feeds
+type Stats struct {
+	Words int
+	// ReadingTime is in minutes, rounded up
+	ReadingTime int
+	// Summary is the plain text before the MoreMarker, or the first
+	// SummaryWords words
+	Summary string
+	// Truncated is set when the summary doesn't contain the whole page
+	Truncated bool
+}
+
+// function Stats counts the words of the page & extracts its summary.
+// Code blocks, HTML & shortcode tags (but not their inner content)
+// aren't counted.
+func (m MD) Stats() Stats {
+	content := expandCallouts(m.Content)
+	words := strings.Fields(m.plainText(content))
+	stats := Stats{Words: len(words)}
+
+	if len(words) > 0 {
+		stats.ReadingTime = (len(words) + WordsPerMinute - 1) / WordsPerMinute
+	}
+
+	if i := moreMarker(content); i >= 0 {
+		summary := strings.Fields(m.plainText(content[:i]))
+		stats.Summary = strings.Join(summary, " ")
+		stats.Truncated = len(summary) < len(words)
+	} else if len(words) > SummaryWords {
+		stats.Summary = strings.Join(words[:SummaryWords], " ") + "…"
+		stats.Truncated = true
+	} else {
+		stats.Summary = strings.Join(words, " ")
+	}
+
+	return stats
+}
+
+// function moreMarker is the index of the first MoreMarker that isn't
+// in a fenced code block, or -1
+func moreMarker(content []byte) int {
+	fences := fencedRanges(content)
+	for i := 0; i < len(content); {
+		j := bytes.Index(content[i:], []byte(MoreMarker))
+		if j < 0 {
+			return -1
+		}
+
+		if end, fenced := inFence(i+j, fences); fenced {
+			i = end
+			continue
+		}
+
+		return i + j
+	}
+
+	return -1
+}
+
+// function plainText renders markdown to text, with blocks separated by
+// new lines
+func (m MD) plainText(content []byte) string {
+	doc := m.parse(shortcodeTagPattern.ReplaceAll(content, nil))
+	text := strings.Builder{}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.CodeBlock, *ast.HTMLBlock, *ast.HTMLSpan, *ast.MathBlock:
+			return ast.SkipChildren
+		case *ast.Text, *ast.Code, *ast.Math:
+			text.Write(n.AsLeaf().Literal)
+		case *ast.Softbreak, *ast.Hardbreak:
+			text.WriteString("\n")
+		case *ast.Paragraph, *ast.Heading, *ast.ListItem, *ast.TableCell:
+			if !entering {
+				text.WriteString("\n")
+			}
+		}
+
+		return ast.GoToNext
+	})
+
+	return text.String()
+}
@@ -0,0 +1,149 @@
+/*
+package minifier minifies built pages, stylesheets & scripts with
+tdewolff/minify and keeps a tally of the bytes saved per asset type.
+
+Minification is conservative: document & end tags, attribute quotes
+and default attribute values are kept, so minified pages render the
+same as their source.
+*/
+package minifier
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+// Types maps the extensions of minifiable files to their media type
+var Types = map[string]string{
+	".html": "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+}
+
+var m = newMinifier()
+
+var (
+	mu      sync.Mutex
+	savings = map[string]*Savings{}
+)
+
+// type Savings is the number of files minified for an asset type and
+// their total size before and after
+type Savings struct {
+	Type   string
+	Files  int
+	Before int
+	After  int
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.Add("text/html", &html.Minifier{
+		KeepDocumentTags:    true,
+		KeepEndTags:         true,
+		KeepQuotes:          true,
+		KeepDefaultAttrVals: true,
+		KeepSpecialComments: true,
+	})
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	// Inline <script> tags without a type
+	m.AddFunc("text/javascript", js.Minify)
+
+	return m
+}
+
+// function Minify minifies data of the given media type (ex. text/css)
+// and records the bytes saved
+func Minify(mediatype string, data []byte) ([]byte, error) {
+	b := bytes.Buffer{}
+	if err := m.Minify(mediatype, &b, bytes.NewReader(data)); err != nil {
+		return data, fmt.Errorf("unable to minify %v %w", mediatype, err)
+	}
+
+	record(mediatype, len(data), b.Len())
+	return b.Bytes(), nil
+}
+
+// function MinifyFile minifies data if the extension of name is in
+// Types, otherwise data is returned as is
+func MinifyFile(name string, data []byte) ([]byte, bool, error) {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".min.css") || strings.HasSuffix(name, ".min.js") {
+		return data, false, nil
+	}
+
+	mediatype, ok := Types[filepath.Ext(name)]
+	if !ok {
+		return data, false, nil
+	}
+
+	out, err := Minify(mediatype, data)
+	return out, err == nil, err
+}
+
+func record(mediatype string, before, after int) {
+	name := mediatype[strings.LastIndex(mediatype, "/")+1:]
+	if name == "javascript" {
+		name = "js"
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := savings[name]
+	if !ok {
+		s = &Savings{Type: name}
+		savings[name] = s
+	}
+
+	s.Files++
+	s.Before += before
+	s.After += after
+}
+
+// function Report returns the savings for each asset type minified
+// since the last call to Reset, ordered by type
+func Report() []Savings {
+	mu.Lock()
+	defer mu.Unlock()
+
+	report := make([]Savings, 0, len(savings))
+	for _, s := range savings {
+		report = append(report, *s)
+	}
+
+	sort.Slice(report, func(i, j int) bool { return report[i].Type < report[j].Type })
+	return report
+}
+
+// function Reset clears the recorded savings
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	savings = map[string]*Savings{}
+}
+
+// function Saved is the number of bytes removed by minification
+func (s Savings) Saved() int {
+	return s.Before - s.After
+}
+
+func (s Savings) String() string {
+	percent := 0.0
+	if s.Before > 0 {
+		percent = float64(s.Saved()) / float64(s.Before) * 100
+	}
+
+	return fmt.Sprintf("%v: %v files, %v → %v bytes (saved %v bytes, %.1f%%)", s.Type, s.Files, s.Before, s.After, s.Saved(), percent)
+}
@@ -0,0 +1,89 @@
+package minifier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMinifier(t *testing.T) {
+	Reset()
+
+	t.Run("pages keep their markup & preformatted text", func(t *testing.T) {
+		page := `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <title>Docs</title>
+    <style>
+      body { color: #ffffff; }
+    </style>
+  </head>
+  <body>
+    <p>Some <strong>bold</strong> <em>text</em></p>
+    <pre><code>func main() {
+    fmt.Println("hi")
+}</code></pre>
+    <script>
+      const greeting = "hi";
+      console.log(greeting);
+    </script>
+  </body>
+</html>`
+
+		out, err := Minify("text/html", []byte(page))
+		if err != nil {
+			t.Fatalf("unable to minify %v", err.Error())
+		}
+
+		got := string(out)
+		for _, want := range []string{
+			`<html lang="en">`, `</html>`, `</p>`, "Some <strong>bold</strong> <em>text</em>",
+			"func main() {\n    fmt.Println(\"hi\")\n}", "color:#fff",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%v should contain %v", got, want)
+			}
+		}
+
+		if len(out) >= len(page) {
+			t.Errorf("page should be smaller, got %v bytes from %v", len(out), len(page))
+		}
+	})
+
+	t.Run("files are minified by extension", func(t *testing.T) {
+		css := []byte("body {\n  margin: 0px;\n}\n")
+		if out, ok, err := MinifyFile("styles.css", css); err != nil || !ok || string(out) != "body{margin:0}" {
+			t.Errorf("got %q %v %v", out, ok, err)
+		}
+
+		js := []byte("function toggle() {\n  return 1 + 1;\n}\n")
+		if out, ok, _ := MinifyFile("theme.js", js); !ok || len(out) >= len(js) {
+			t.Errorf("js should be minified, got %q", out)
+		}
+
+		for _, name := range []string{"vendor.min.js", "logo.png"} {
+			if _, ok, _ := MinifyFile(name, []byte("x")); ok {
+				t.Errorf("%v should be skipped", name)
+			}
+		}
+	})
+
+	t.Run("savings are reported per type", func(t *testing.T) {
+		report := Report()
+		types := []string{}
+		for _, s := range report {
+			types = append(types, s.Type)
+			if s.Saved() <= 0 {
+				t.Errorf("%v should have saved bytes", s)
+			}
+		}
+
+		if strings.Join(types, ",") != "css,html,js" {
+			t.Errorf("got %v, want css,html,js", types)
+		}
+
+		Reset()
+		if len(Report()) != 0 {
+			t.Error("Reset should clear the report")
+		}
+	})
+}
@@ -1,8 +1,10 @@
 package theme
 
 import (
-	_ "embed"
+	"embed"
 	"fmt"
+	"path"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -59,6 +61,26 @@ var DefaultLightThemeFile []byte
 //go:embed css/dark/tokyo-city-dark.yml
 var DefaultDarkThemeFile []byte
 
+//go:embed css/light/*.yml css/dark/*.yml
+var themeFiles embed.FS
+
+// function List returns the names of the embedded themes for a variant
+// (light or dark), ex. tokyo-city-light
+func List(variant string) []string {
+	entries, err := themeFiles.ReadDir(path.Join("css", variant))
+	if err != nil {
+		return []string{}
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+
+	sort.Strings(names)
+	return names
+}
+
 // Unmarshal YAML file into a Theme struct
 func ParseTheme(data []byte) (*Theme, error) {
 	t := Theme{}
@@ -106,20 +128,36 @@ func (s *styleCtx) with(t string) *styleCtx {
 	return s
 }
 
-// function BuildTheme parses the default theme files and returns a theme
-//
-// TODO: take a theme slug to select a theme and then executes
-// the theme variable & stylesheet templates. These are concatenated and then
-// the contents are returns as a string.
-func BuildTheme(args ...string) (string, error) {
+// function Open reads the embedded theme with the given name for a
+// variant (light or dark), ex. tokyo-city-light. An empty name is the
+// default theme of the variant.
+func Open(variant, name string) (*Theme, error) {
+	if name == "" && variant == "dark" {
+		return ParseTheme(DefaultDarkThemeFile)
+	} else if name == "" {
+		return ParseTheme(DefaultLightThemeFile)
+	}
+
+	data, err := themeFiles.ReadFile(path.Join("css", variant, name+".yml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown %v theme %v", variant, name)
+	}
+
+	return ParseTheme(data)
+}
+
+// function BuildTheme executes the theme variable & stylesheet templates
+// with the light & dark themes named in the config (see Open) and
+// returns them concatenated.
+func BuildTheme(light, dark string) (string, error) {
 	theme_ctx := themeCtx{}
 	style_ctx := styleCtx{}
 	b := strings.Builder{}
 
-	light_theme, err := ParseTheme(DefaultLightThemeFile)
+	light_theme, err := Open("light", light)
 	errs := theme_ctx.buildStack([]error{}, err, light_theme)
 
-	dark_theme, err := ParseTheme(DefaultDarkThemeFile)
+	dark_theme, err := Open("dark", dark)
 	errs = theme_ctx.buildStack(errs, err, dark_theme)
 
 	if len(errs) == 2 {
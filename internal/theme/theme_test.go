@@ -73,7 +73,7 @@ palette:
 	})
 
 	t.Run("build theme", func(t *testing.T) {
-		got, err := BuildTheme()
+		got, err := BuildTheme("", "")
 
 		if err != nil {
 			t.Fatalf("failed to build theme with error %v", err.Error())
@@ -87,4 +87,26 @@ palette:
 			t.Error("should contain base16 theming variables")
 		}
 	})
+	t.Run("build theme with the configured themes", func(t *testing.T) {
+		got, err := BuildTheme("catppuccin-latte", "rose-pine")
+		if got == "" {
+			t.Fatalf("failed to build theme with error %v", err)
+		}
+
+		for _, want := range []string{"#eff1f5", "#191724"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("stylesheet should contain %v from the configured themes", want)
+			}
+		}
+
+		if strings.Contains(got, "#FBFBFD") {
+			t.Error("stylesheet shouldn't contain the default light theme")
+		}
+	})
+
+	t.Run("unknown themes are an error", func(t *testing.T) {
+		if _, err := Open("dark", "nope"); err == nil {
+			t.Error("opening a theme that doesn't exist should fail")
+		}
+	})
 }
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// type Ignore matches slash separated paths against a list of
+// .gitignore style patterns:
+//
+//   - a pattern without a slash (ex. *.psd) matches a name at any depth
+//   - a pattern with a slash (ex. drafts/*.png) is relative to the root
+//   - a trailing slash (ex. tmp/) only matches directories
+//   - ** matches any number of directories (ex. **/raw/*.png)
+//   - a leading ! re-includes a path excluded by an earlier pattern
+//
+// Like git, later patterns take precedence.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// function NewIgnore compiles patterns, skipping blank lines & comments
+func NewIgnore(patterns []string) *Ignore {
+	i := &Ignore{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		r := ignoreRule{}
+		if strings.HasPrefix(p, "!") {
+			r.negate = true
+			p = p[1:]
+		}
+
+		if strings.HasSuffix(p, "/") {
+			r.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+
+		r.anchored = strings.Contains(p, "/")
+		r.segments = strings.Split(strings.TrimPrefix(p, "/"), "/")
+		i.rules = append(i.rules, r)
+	}
+
+	return i
+}
+
+// function Match reports whether rel, a path relative to the root of
+// the patterns, is ignored
+func (i *Ignore) Match(rel string, isDir bool) bool {
+	if i == nil {
+		return false
+	}
+
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	ignored := false
+	for _, r := range i.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		var matched bool
+		if r.anchored {
+			matched = matchSegments(r.segments, parts)
+		} else {
+			matched, _ = path.Match(r.segments[0], parts[len(parts)-1])
+		}
+
+		if matched {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for n := 0; n <= len(parts); n++ {
+			if matchSegments(pattern[1:], parts[n:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], parts[1:])
+}
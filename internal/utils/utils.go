@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -118,6 +117,8 @@ func CreateDir(d string) string {
 	return d
 }
 
+// function CopyFile copies {src}/{fname} to {dest}/{fname}, keeping
+// the file's mode
 func CopyFile(fname, src, dest string) (string, error) {
 	src_path := fmt.Sprintf("%v/%v", src, fname)
 	dest_path := fmt.Sprintf("%v/%v", dest, fname)
@@ -128,20 +129,32 @@ func CopyFile(fname, src, dest string) (string, error) {
 		)
 	}
 
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(src_path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
 	_ = os.Remove(dest_path)
-	f, err := os.Create(dest_path)
+	f, err := os.OpenFile(dest_path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 	if err != nil {
 		return "", fmt.Errorf("unable to create file at %v %v",
 			src_path, err.Error(),
 		)
 	}
 
-	code, err := io.WriteString(f, string(data))
+	defer f.Close()
+
+	code, err := f.Write(data)
 	if err != nil {
 		return "", fmt.Errorf("unable to write file at %v with code %v %v",
 			dest_path, code, err.Error(),
 		)
 	}
 
+	// OpenFile's mode is subject to the umask
+	if err := f.Chmod(mode); err != nil {
+		return "", fmt.Errorf("unable to set mode of %v %v", dest_path, err.Error())
+	}
+
 	return dest_path, nil
 }
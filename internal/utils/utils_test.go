@@ -171,6 +171,32 @@ func TestLibsPackage(t *testing.T) {
 		os.Remove("tmp.json")
 	})
 
+	t.Run("Ignore matches .gitignore style patterns", func(t *testing.T) {
+		ignore := NewIgnore([]string{"# comment", "*.psd", "drafts/", "/build/*.log", "**/raw/*.png", "!keep.psd"})
+		cases := []struct {
+			path    string
+			isDir   bool
+			ignored bool
+		}{
+			{"logo.psd", false, true},
+			{"images/logo.psd", false, true},
+			{"images/keep.psd", false, false},
+			{"drafts", true, true},
+			{"drafts", false, false},
+			{"build/out.log", false, true},
+			{"nested/build/out.log", false, false},
+			{"a/b/raw/photo.png", false, true},
+			{"raw/photo.png", false, true},
+			{"images/logo.png", false, false},
+		}
+
+		for _, c := range cases {
+			if got := ignore.Match(c.path, c.isDir); got != c.ignored {
+				t.Errorf("%v (dir: %v) should be ignored: %v, got %v", c.path, c.isDir, c.ignored, got)
+			}
+		}
+	})
+
 	t.Run("Error states for lib functions", func(t *testing.T) {
 		t.Run("OpenFileUnsafe", func(t *testing.T) {
 			content := OpenFileUnsafe("non-existent-file.md")
@@ -0,0 +1,309 @@
+package view
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
+)
+
+// dateLayouts are tried, in order, when a template passes a string
+// to dateFormat
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// function FuncMap builds the set of functions available to every
+// layout parsed by GetTemplate. Functions that depend on the site's
+// configuration (absURL, relURL & asset) fall back to returning their
+// input unchanged when conf is nil.
+//
+//	dateFormat LAYOUT VALUE     formats a time.Time or date string
+//	now                         the current time
+//	absURL PATH                 PATH joined to meta.URL
+//	relURL PATH                 PATH joined to the path portion of meta.URL
+//	markdownify STRING          renders STRING as markdown
+//	truncate N STRING           shortens STRING to N characters
+//	where LIST KEY VALUE        filters LIST to items where KEY equals VALUE
+//	sortBy LIST KEY [desc]      sorts LIST by KEY
+//	asset NAME                  resolves NAME to its (fingerprinted) URL
+//	readFile PATH               reads the contents of PATH
+func FuncMap(conf *config.Config) template.FuncMap {
+	assets := assetResolver(conf)
+
+	return template.FuncMap{
+		"dateFormat":  dateFormat,
+		"now":         time.Now,
+		"absURL":      func(p string) string { return absURL(conf, p) },
+		"relURL":      func(p string) string { return relURL(conf, p) },
+		"markdownify": markdownify,
+		"truncate":    truncate,
+		"where":       where,
+		"sortBy":      sortBy,
+		"asset":       assets,
+		"readFile":    readFile,
+	}
+}
+
+func dateFormat(layout string, v any) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+
+		return t.Format(layout), nil
+	case string:
+		for _, l := range dateLayouts {
+			if parsed, err := time.Parse(l, t); err == nil {
+				return parsed.Format(layout), nil
+			}
+		}
+
+		return "", fmt.Errorf("unable to parse date %v", t)
+	default:
+		return "", fmt.Errorf("unable to format %v (%T) as a date", v, v)
+	}
+}
+
+func absURL(conf *config.Config, p string) string {
+	if conf == nil || conf.Metadata.URL == "" || isAbsoluteURL(p) {
+		return p
+	}
+
+	base := strings.TrimSuffix(conf.Metadata.URL, "/")
+	return base + "/" + strings.TrimPrefix(p, "/")
+}
+
+func relURL(conf *config.Config, p string) string {
+	if isAbsoluteURL(p) {
+		return p
+	}
+
+	base := "/"
+	if conf != nil && conf.Metadata.URL != "" {
+		if u, err := url.Parse(conf.Metadata.URL); err == nil && u.Path != "" {
+			base = u.Path
+		}
+	}
+
+	r := path.Join(base, p)
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(r, "/") {
+		r += "/"
+	}
+
+	return r
+}
+
+func isAbsoluteURL(p string) bool {
+	u, err := url.Parse(p)
+	return err == nil && u.IsAbs()
+}
+
+func markdownify(s string) template.HTML {
+	m := md.MD{Content: []byte(s)}
+	return template.HTML(m.HTML())
+}
+
+// function truncate shortens s to at most n runes, breaking on the
+// last space where possible and appending an ellipsis
+func truncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	r := []rune(s)[:n]
+	t := string(r)
+	if i := strings.LastIndex(t, " "); i > 0 {
+		t = t[:i]
+	}
+
+	return strings.TrimRight(t, " .,;:") + "…"
+}
+
+// function assetResolver maps asset names (ex. styles.css) to the URL
+// they're served from. Fingerprinted names are read on first use from
+// the manifest in the static path ({build_dir}/assets), if there is one.
+func assetResolver(conf *config.Config) func(string) string {
+	once := sync.Once{}
+	manifest := map[string]string{}
+
+	return func(name string) string {
+		name = strings.TrimPrefix(name, "/")
+		name = strings.TrimPrefix(name, "assets/")
+
+		once.Do(func() {
+			if conf == nil {
+				return
+			}
+
+			data, err := os.ReadFile(fmt.Sprintf("%v/manifest.json", conf.Build.GetStaticPath()))
+			if err != nil {
+				return
+			}
+
+			json.Unmarshal(data, &manifest)
+		})
+
+		if hashed, ok := manifest[name]; ok {
+			name = hashed
+		}
+
+		return relURL(conf, "assets/"+name)
+	}
+}
+
+func readFile(p string) (string, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("readFile: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// function where returns a new slice with the items from collection
+// where the field (or map key) at key is equal to value. Keys can be
+// dotted to reach nested fields, ex. "Markdown.Frontmatter.Layout"
+func where(collection any, key string, value any) (any, error) {
+	v := reflect.ValueOf(collection)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("where: can't iterate over %T", collection)
+	}
+
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		field, ok := lookup(item, key)
+		if !ok {
+			continue
+		}
+
+		if equal(field, value) {
+			out = reflect.Append(out, item)
+		}
+	}
+
+	return out.Interface(), nil
+}
+
+// function sortBy returns a sorted copy of collection ordered by the
+// field (or map key) at key. Pass "desc" to reverse the order.
+func sortBy(collection any, key string, order ...string) (any, error) {
+	v := reflect.ValueOf(collection)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("sortBy: can't iterate over %T", collection)
+	}
+
+	out := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), v.Len(), v.Len())
+	reflect.Copy(out, v)
+
+	desc := len(order) > 0 && strings.ToLower(order[0]) == "desc"
+
+	sort.SliceStable(out.Interface(), func(i, j int) bool {
+		a, _ := lookup(out.Index(i), key)
+		b, _ := lookup(out.Index(j), key)
+		if desc {
+			return less(b, a)
+		}
+
+		return less(a, b)
+	})
+
+	return out.Interface(), nil
+}
+
+func lookup(v reflect.Value, key string) (reflect.Value, bool) {
+	for _, part := range strings.Split(key, ".") {
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Struct:
+			v = v.FieldByName(part)
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(part))
+		default:
+			return reflect.Value{}, false
+		}
+
+		if !v.IsValid() {
+			return reflect.Value{}, false
+		}
+	}
+
+	return indirect(v), true
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func equal(field reflect.Value, value any) bool {
+	if !field.IsValid() {
+		return value == nil
+	}
+
+	if t, ok := field.Interface().(time.Time); ok {
+		if u, ok := value.(time.Time); ok {
+			return t.Equal(u)
+		}
+	}
+
+	return fmt.Sprint(field.Interface()) == fmt.Sprint(value)
+}
+
+func less(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+
+	if t, ok := a.Interface().(time.Time); ok {
+		if u, ok := b.Interface().(time.Time); ok {
+			return t.Before(u)
+		}
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if b.CanInt() {
+			return a.Int() < b.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if b.CanUint() {
+			return a.Uint() < b.Uint()
+		}
+	case reflect.Float32, reflect.Float64:
+		if b.CanFloat() {
+			return a.Float() < b.Float()
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool {
+			return !a.Bool() && b.Bool()
+		}
+	}
+
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
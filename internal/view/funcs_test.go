@@ -0,0 +1,135 @@
+package view
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/documango/internal/config"
+)
+
+type testPage struct {
+	Title  string
+	Weight int
+	Draft  bool
+}
+
+func TestFuncMap(t *testing.T) {
+	conf := config.NewDefaultConfig()
+	conf.Metadata.URL = "https://example.com/docs/"
+
+	pages := []*testPage{
+		{Title: "Beta", Weight: 2},
+		{Title: "Alpha", Weight: 3, Draft: true},
+		{Title: "Gamma", Weight: 1},
+	}
+
+	render := func(t *testing.T, src string, data any) string {
+		templ, err := template.New("test").Funcs(FuncMap(&conf)).Parse(src)
+		if err != nil {
+			t.Fatalf("unable to parse template %v", err.Error())
+		}
+
+		b := bytes.Buffer{}
+		if err = templ.Execute(&b, data); err != nil {
+			t.Fatalf("unable to execute template %v", err.Error())
+		}
+
+		return b.String()
+	}
+
+	t.Run("dateFormat formats times and date strings", func(t *testing.T) {
+		d := time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC)
+		if got := render(t, `{{ dateFormat "Jan 2, 2006" . }}`, d); got != "Jan 5, 2025" {
+			t.Errorf("got %v, want Jan 5, 2025", got)
+		}
+
+		if got := render(t, `{{ dateFormat "2006" "2024-03-01" }}`, nil); got != "2024" {
+			t.Errorf("got %v, want 2024", got)
+		}
+	})
+
+	t.Run("absURL & relURL use meta.URL", func(t *testing.T) {
+		if got := render(t, `{{ absURL "/about" }}`, nil); got != "https://example.com/docs/about" {
+			t.Errorf("got %v", got)
+		}
+
+		if got := render(t, `{{ relURL "about" }}`, nil); got != "/docs/about" {
+			t.Errorf("got %v", got)
+		}
+
+		if got := render(t, `{{ absURL "https://github.com" }}`, nil); got != "https://github.com" {
+			t.Errorf("absolute urls should be untouched, got %v", got)
+		}
+	})
+
+	t.Run("markdownify renders markdown", func(t *testing.T) {
+		if got := render(t, `{{ markdownify "**bold**" }}`, nil); !strings.Contains(got, "<strong>bold</strong>") {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("truncate shortens on word boundaries", func(t *testing.T) {
+		if got := truncate(12, "the quick brown fox"); got != "the quick…" {
+			t.Errorf("got %v", got)
+		}
+
+		if got := truncate(50, "short"); got != "short" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("where filters collections", func(t *testing.T) {
+		got := render(t, `{{ range where . "Draft" false }}{{ .Title }} {{ end }}`, pages)
+		if got != "Beta Gamma " {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("sortBy orders collections", func(t *testing.T) {
+		got := render(t, `{{ range sortBy . "Weight" }}{{ .Title }} {{ end }}`, pages)
+		if got != "Gamma Beta Alpha " {
+			t.Errorf("got %v", got)
+		}
+
+		got = render(t, `{{ range sortBy . "Title" "desc" }}{{ .Title }} {{ end }}`, pages)
+		if got != "Gamma Beta Alpha " {
+			t.Errorf("got %v", got)
+		}
+
+		if pages[0].Title != "Beta" {
+			t.Error("sortBy should not mutate the original collection")
+		}
+	})
+
+	t.Run("asset falls back to the assets dir without a manifest", func(t *testing.T) {
+		if got := render(t, `{{ asset "styles.css" }}`, nil); got != "/docs/assets/styles.css" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("asset resolves fingerprinted names from the manifest", func(t *testing.T) {
+		c := conf
+		c.Build.BuildDir = t.TempDir()
+		os.MkdirAll(c.Build.GetStaticPath(), os.ModePerm)
+		os.WriteFile(filepath.Join(c.Build.GetStaticPath(), "manifest.json"), []byte(`{"styles.css": "styles.5d41402a.css"}`), 0644)
+
+		templ := template.Must(template.New("test").Funcs(FuncMap(&c)).Parse(`{{ asset "styles.css" }} {{ asset "/assets/theme.js" }}`))
+		b := bytes.Buffer{}
+		templ.Execute(&b, nil)
+
+		if got := b.String(); got != "/docs/assets/styles.5d41402a.css /docs/assets/theme.js" {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("readFile returns an error for missing files", func(t *testing.T) {
+		if _, err := readFile("non-existent-file.md"); err == nil {
+			t.Error("should have failed to read file")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package view
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/desertthunder/documango/internal/config"
+)
+
+// MainMenu is the menu rendered in the header of the default layout
+const MainMenu string = "main"
+
+// type MenuEntry is a single link in a menu
+type MenuEntry struct {
+	Name     string
+	URL      string
+	Weight   int
+	External bool
+}
+
+// type Menus maps a menu name to its entries, ex. {{ range .Menus.main }}
+type Menus map[string][]*MenuEntry
+
+// function BuildMenus creates the menus defined by [[menu.{name}]]
+// tables in config.toml & by pages that opt in with the menus key in
+// their frontmatter.
+//
+// When nothing defines the main menu, it falls back to a link for each
+// page in the site that doesn't set nav_exclude.
+func BuildMenus(conf *config.Config, site *Site) Menus {
+	menus := Menus{}
+
+	if conf != nil {
+		for name, items := range conf.Menus {
+			for _, item := range items {
+				menus[name] = append(menus[name], &MenuEntry{
+					Name:     item.Name,
+					URL:      item.URL,
+					Weight:   item.Weight,
+					External: item.External,
+				})
+			}
+		}
+	}
+
+	if site == nil {
+		return menus
+	}
+
+	for _, p := range site.Pages {
+		if p.navExclude {
+			continue
+		}
+
+		for _, name := range p.menus {
+			menus[name] = append(menus[name], p.menuEntry())
+		}
+	}
+
+	if len(menus[MainMenu]) == 0 {
+		for _, p := range site.Pages {
+			if !p.navExclude {
+				menus[MainMenu] = append(menus[MainMenu], p.menuEntry())
+			}
+		}
+
+		// Home should always lead the auto-generated menu
+		i := slices.IndexFunc(menus[MainMenu], func(e *MenuEntry) bool { return e.URL == "/" })
+		if i > 0 {
+			home := menus[MainMenu][i]
+			menus[MainMenu] = slices.Insert(slices.Delete(menus[MainMenu], i, i+1), 0, home)
+		}
+	}
+
+	for name := range menus {
+		entries := menus[name]
+		sort.SliceStable(entries, func(i, j int) bool {
+			return byWeight(entries[i].Weight, entries[j].Weight)
+		})
+	}
+
+	return menus
+}
+
+// function byWeight orders weighted items before unweighted ones
+// (a weight of 0) and otherwise lowest weight first
+func byWeight(a, b int) bool {
+	if a == 0 || b == 0 {
+		return a != 0 && b == 0
+	}
+
+	return a < b
+}
+
+func (p *Page) menuEntry() *MenuEntry {
+	name := p.Title
+	if p.navTitle != "" {
+		name = p.navTitle
+	} else if p.URL == "/" {
+		name = "Home"
+	}
+
+	return &MenuEntry{Name: name, URL: p.URL, Weight: p.Weight}
+}
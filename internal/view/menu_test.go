@@ -0,0 +1,73 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/desertthunder/documango/internal/config"
+)
+
+func TestMenus(t *testing.T) {
+	site := &Site{Pages: []*Page{
+		{Title: "About", URL: "/about"},
+		{Title: "Documango", URL: "/"},
+		{Title: "Setup", URL: "/setup", Weight: 1, navTitle: "Get Started", menus: []string{"footer"}},
+		{Title: "Secret", URL: "/secret", menus: []string{"footer"}, navExclude: true},
+	}}
+
+	t.Run("main menu falls back to every page", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		menus := BuildMenus(&conf, site)
+
+		if got := len(menus[MainMenu]); got != 3 {
+			t.Fatalf("there should be 3 entries but there are %v", got)
+		}
+
+		if menus[MainMenu][0].Name != "Get Started" {
+			t.Errorf("weighted entries should come first, got %v", menus[MainMenu][0].Name)
+		}
+
+		if menus[MainMenu][1].Name != "Home" {
+			t.Errorf("home should lead unweighted entries, got %v", menus[MainMenu][1].Name)
+		}
+
+		for _, e := range menus[MainMenu] {
+			if e.URL == "/secret" {
+				t.Error("pages with nav_exclude should be left out")
+			}
+		}
+	})
+
+	t.Run("config entries replace the auto-generated main menu", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		conf.Menus = map[string][]config.MenuItem{
+			MainMenu: {
+				{Name: "GitHub", URL: "https://github.com", Weight: 2, External: true},
+				{Name: "Docs", URL: "/", Weight: 1},
+			},
+		}
+
+		menus := BuildMenus(&conf, site)
+		main := menus[MainMenu]
+
+		if len(main) != 2 {
+			t.Fatalf("there should be 2 entries but there are %v", len(main))
+		}
+
+		if main[0].Name != "Docs" || !main[1].External {
+			t.Errorf("entries should be sorted by weight %v %v", main[0].Name, main[1].Name)
+		}
+	})
+
+	t.Run("pages opt in to menus with frontmatter", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		footer := BuildMenus(&conf, site)["footer"]
+
+		if len(footer) != 1 || footer[0].URL != "/setup" {
+			t.Errorf("footer should only have the setup page %v", footer)
+		}
+
+		if footer[0].Name != "Get Started" {
+			t.Errorf("entries should use the nav title, got %v", footer[0].Name)
+		}
+	})
+}
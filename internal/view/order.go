@@ -0,0 +1,76 @@
+package view
+
+import (
+	"sort"
+	"strings"
+)
+
+// Values for sort_by in a section's index frontmatter
+const (
+	SortByWeight string = "weight"
+	SortByDate   string = "date"
+	SortByTitle  string = "title"
+)
+
+// function sortChildren orders a section's pages. By default pages
+// are sorted by weight (see byWeight) and then by filename. Sections
+// that set sort_by = "date" (ex. a blog) list the newest pages first
+// and sort_by = "title" sorts them alphabetically.
+func (p *Page) sortChildren() {
+	c := p.Children
+
+	switch strings.ToLower(p.sortBy) {
+	case SortByDate:
+		sort.SliceStable(c, func(i, j int) bool {
+			if c[i].Date.IsZero() || c[j].Date.IsZero() {
+				return !c[i].Date.IsZero() && c[j].Date.IsZero()
+			}
+
+			return c[i].Date.After(c[j].Date)
+		})
+	case SortByTitle:
+		sort.SliceStable(c, func(i, j int) bool {
+			return strings.ToLower(c[i].Title) < strings.ToLower(c[j].Title)
+		})
+	default:
+		sort.SliceStable(c, func(i, j int) bool {
+			return byWeight(c[i].Weight, c[j].Weight)
+		})
+	}
+}
+
+// function WithPrevNext links each page to the pages before and
+// after it in its section. Section index pages are skipped, so the
+// links only step through a section's own content.
+func WithPrevNext(views []*View) []*View {
+	for _, v := range views {
+		if v.Page == nil || v.Page.IsSection || v.Page.Parent == nil {
+			continue
+		}
+
+		pages := []*Page{}
+		for _, c := range v.Page.Parent.Children {
+			if !c.IsSection {
+				pages = append(pages, c)
+			}
+		}
+
+		for i, c := range pages {
+			if c != v.Page {
+				continue
+			}
+
+			if i > 0 {
+				v.Prev = pages[i-1]
+			}
+
+			if i < len(pages)-1 {
+				v.Next = pages[i+1]
+			}
+		}
+
+		v.Page.Prev, v.Page.Next = v.Prev, v.Next
+	}
+
+	return views
+}
@@ -0,0 +1,76 @@
+package view
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrdering(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2025, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("pages are sorted by weight with unweighted pages last", func(t *testing.T) {
+		section := &Page{IsSection: true, Children: []*Page{
+			{Title: "Usage"},
+			{Title: "Installation", Weight: 1},
+			{Title: "Configuration", Weight: 2},
+		}}
+
+		section.sortChildren()
+
+		want := []string{"Installation", "Configuration", "Usage"}
+		for i, c := range section.Children {
+			if c.Title != want[i] {
+				t.Errorf("got %v at %v, want %v", c.Title, i, want[i])
+			}
+		}
+	})
+
+	t.Run("sort_by = date lists the newest pages first", func(t *testing.T) {
+		section := &Page{IsSection: true, sortBy: SortByDate, Children: []*Page{
+			{Title: "Undated"},
+			{Title: "Older", Date: day(1)},
+			{Title: "Newer", Date: day(9)},
+		}}
+
+		section.sortChildren()
+
+		want := []string{"Newer", "Older", "Undated"}
+		for i, c := range section.Children {
+			if c.Title != want[i] {
+				t.Errorf("got %v at %v, want %v", c.Title, i, want[i])
+			}
+		}
+	})
+
+	t.Run("views are linked to their neighbours in a section", func(t *testing.T) {
+		section := &Page{IsSection: true}
+		views := []*View{}
+		for _, title := range []string{"Installation", "Configuration", "Usage"} {
+			p := &Page{Title: title, Parent: section}
+			section.Children = append(section.Children, p)
+			views = append(views, &View{Page: p})
+		}
+
+		section.Children = append(section.Children, &Page{Title: "Advanced", IsSection: true, Parent: section})
+
+		WithPrevNext(views)
+
+		if views[0].Prev != nil || views[0].Next.Title != "Configuration" {
+			t.Errorf("first page should only have a next page %v %v", views[0].Prev, views[0].Next)
+		}
+
+		if views[1].Prev.Title != "Installation" || views[1].Next.Title != "Usage" {
+			t.Errorf("middle page should link both ways %v %v", views[1].Prev, views[1].Next)
+		}
+
+		if views[2].Next != nil {
+			t.Errorf("last page should not link to subsections %v", views[2].Next)
+		}
+
+		if views[1].Page.Next != views[1].Next {
+			t.Error("page & view should share prev/next links")
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
+)
+
+var bodyPattern = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+// function PreviewConfig is the preview of unpublished pages selected
+// by the drafts & future build options
+func PreviewConfig(c *config.Config) md.Preview {
+	return md.Preview{Drafts: c.Build.Drafts, Future: c.Build.Future}
+}
+
+// function PreviewBanner adds a banner to the top of the body of html,
+// the rendered page of v, when v is only built as a preview. It's styled
+// inline (with the theme's colors when they're available) so it shows up
+// with any template.
+func (v *View) PreviewBanner(html []byte) []byte {
+	if len(v.Preview) == 0 {
+		return html
+	}
+
+	messages := []string{}
+	for _, reason := range v.Preview {
+		switch {
+		case reason == md.Draft:
+			messages = append(messages, "Draft: this page is only built with --drafts")
+		case reason == md.Scheduled && v.Markdown.Frontmatter != nil:
+			messages = append(messages, fmt.Sprintf(
+				"Scheduled: this page is published on %v and only built with --future",
+				v.Markdown.Frontmatter.PublishDate.Format(time.DateOnly),
+			))
+		}
+	}
+
+	message := strings.Join(messages, ". ")
+
+	banner := fmt.Sprintf(
+		`<div class="preview-banner" role="status" style="position: sticky; top: 0; z-index: 100; padding: 0.5rem 1rem; text-align: center; font-weight: bold; background: var(--base0A, #f4bf75); color: var(--base00, #181818);">%v</div>`,
+		template.HTMLEscapeString(message),
+	)
+
+	loc := bodyPattern.FindIndex(html)
+	if loc == nil {
+		return append([]byte(banner), html...)
+	}
+
+	b := bytes.Buffer{}
+	b.Write(html[:loc[1]])
+	b.WriteString(banner)
+	b.Write(html[loc[1]:])
+
+	return b.Bytes()
+}
@@ -0,0 +1,51 @@
+package view
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/desertthunder/documango/internal/md"
+)
+
+func TestPreviewBanner(t *testing.T) {
+	page := []byte(`<!doctype html><html><body class="page"><main>Hello</main></body></html>`)
+
+	t.Run("published pages are untouched", func(t *testing.T) {
+		v := View{Markdown: &md.MD{}}
+		if got := v.PreviewBanner(page); string(got) != string(page) {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("drafts get a banner at the top of the body", func(t *testing.T) {
+		v := View{Markdown: &md.MD{Frontmatter: &md.Frontmatter{Draft: true}}, Preview: []string{md.Draft}}
+		got := string(v.PreviewBanner(page))
+		if !strings.HasPrefix(got, `<!doctype html><html><body class="page"><div class="preview-banner"`) {
+			t.Errorf("the banner should follow the body tag %v", got)
+		}
+
+		if !strings.Contains(got, "Draft: this page is only built with --drafts") {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("scheduled pages show their publish date", func(t *testing.T) {
+		fm := &md.Frontmatter{PublishDate: time.Date(2030, time.March, 4, 0, 0, 0, 0, time.UTC)}
+		v := View{Markdown: &md.MD{Frontmatter: fm}, Preview: []string{md.Scheduled}}
+		if got := string(v.PreviewBanner([]byte("<p>fragment</p>"))); !strings.HasPrefix(got, `<div class="preview-banner"`) || !strings.Contains(got, "published on 2030-03-04") {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("scheduled drafts show both reasons", func(t *testing.T) {
+		fm := &md.Frontmatter{Draft: true, PublishDate: time.Date(2030, time.March, 4, 0, 0, 0, 0, time.UTC)}
+		v := View{Markdown: &md.MD{Frontmatter: fm}, Preview: []string{md.Draft, md.Scheduled}}
+		got := string(v.PreviewBanner(page))
+		for _, want := range []string{"Draft: this page is only built with --drafts", "published on 2030-03-04"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%v should contain %v", got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package view
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/desertthunder/documango/internal/md"
+)
+
+// function WithSections makes every directory in the content tree a
+// section. A section's landing page is its index file (_index.md,
+// index.md or README.md) and directories without one get a generated
+// page that lists their contents (see WithListings).
+//
+// Generated section views are inserted before the first view in their
+// directory, so directory order is preserved.
+func WithSections(views []*View, contentDir, templateDir string) []*View {
+	indexed := map[string]bool{}
+	for _, v := range views {
+		dir, name := splitPath(v.Path)
+		if isIndex(name) {
+			indexed[strings.ToLower(dir)] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	out := make([]*View, 0, len(views))
+	for _, v := range views {
+		dir, _ := splitPath(v.Path)
+
+		for _, d := range ancestors(dir) {
+			key := strings.ToLower(d)
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			if !indexed[key] {
+				out = append(out, newSectionView(d, contentDir, templateDir))
+			}
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
+// function WithListings fills the contents of generated section pages
+// with a list of links to the section's children, with their reading
+// time & summary. It expects pages to have been created by WithSite.
+//
+// Titles & summaries are plain text, so they're escaped before they're
+// written into the markdown of the listing. The summary follows a hard
+// line break, which keeps it in the list item.
+func WithListings(views []*View) []*View {
+	for _, v := range views {
+		if !v.generated || v.Page == nil {
+			continue
+		}
+
+		b := strings.Builder{}
+		for _, c := range v.Page.Children {
+			b.WriteString(fmt.Sprintf("- [%v](%v)", md.Escape(c.Title), c.URL))
+			if c.ReadingTime > 0 {
+				b.WriteString(fmt.Sprintf(" · %v min read", c.ReadingTime))
+			}
+
+			if summary := md.Escape(c.Summary); summary != "" {
+				b.WriteString("  \n  " + summary)
+			}
+
+			b.WriteString("\n")
+		}
+
+		v.Markdown.Content = []byte(b.String())
+	}
+
+	return views
+}
+
+func newSectionView(dir, contentDir, templateDir string) *View {
+	m := &md.MD{FilePath: filepath.Join(contentDir, dir, "_index.md")}
+
+	if dir != "" {
+		_, name := splitPath(dir)
+		m.Frontmatter = &md.Frontmatter{Title: Caser.String(name)}
+	}
+
+	return &View{
+		Path:        strings.TrimPrefix(dir+"/_index", "/"),
+		Markdown:    m,
+		templateDir: templateDir,
+		generated:   true,
+	}
+}
+
+// function relativePath is the path of a content file relative to the
+// content directory without its extension, ex. guides/setup
+func relativePath(contentDir, fp string) string {
+	rel, err := filepath.Rel(contentDir, fp)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(fp)
+	}
+
+	rel = filepath.ToSlash(rel)
+	return strings.TrimSuffix(rel, filepath.Ext(rel))
+}
+
+// function splitPath splits a slash separated path into its
+// directory and final element
+func splitPath(p string) (string, string) {
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return "", p
+	}
+
+	return p[:i], p[i+1:]
+}
+
+// function ancestors lists dir and each of its parents, outermost
+// first, starting with the content root ("")
+func ancestors(dir string) []string {
+	dirs := []string{""}
+	if dir == "" {
+		return dirs
+	}
+
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+	}
+
+	return dirs
+}
+
+func isIndex(name string) bool {
+	switch strings.ToLower(name) {
+	case "index", "_index", "readme":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,234 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
+)
+
+func TestSections(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":                 "# Home",
+		"guides/_index.md":          "+++\ntitle = \"All Guides\"\n+++\n\n# Guides",
+		"guides/setup.md":           "# Setup\n\nSee [deploying](./deploy.md#steps) or [tuning](advanced/tuning.md).",
+		"guides/deploy.md":          "# Deploy",
+		"guides/advanced/tuning.md": "# Tuning",
+		"blog/first-post.md":        "+++\ntitle = \"First Post\"\n+++\n\nHello, read the [old post](./old.md)",
+	}
+
+	for name, content := range files {
+		fp := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+		if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %v %v", name, err.Error())
+		}
+	}
+
+	views, err := NewViews(dir, "", md.Preview{})
+	if err != nil {
+		t.Fatalf("unable to build views %v", err.Error())
+	}
+
+	byRoute := map[string]*View{}
+	for _, v := range views {
+		byRoute[v.Route()] = v
+	}
+
+	t.Run("section indexes are routed to their directory", func(t *testing.T) {
+		for _, route := range []string{"/", "/guides/", "/guides/advanced/", "/blog/", "/guides/setup"} {
+			if _, ok := byRoute[route]; !ok {
+				t.Errorf("there should be a view for %v", route)
+			}
+		}
+
+		if v := byRoute["/guides/"]; v != nil && v.Path != "guides/index" {
+			t.Errorf("got %v, want guides/index", v.Path)
+		}
+	})
+
+	t.Run("directories without an index get a generated listing", func(t *testing.T) {
+		v := byRoute["/blog/"]
+		if v == nil || !v.generated {
+			t.Fatal("/blog/ should be a generated section")
+		}
+
+		if v.Page.Title != "Blog" {
+			t.Errorf("got %v, want Blog", v.Page.Title)
+		}
+
+		if !strings.Contains(string(v.Markdown.Content), "[First Post](/blog/first-post) · 1 min read  \n  Hello, read the old post") {
+			t.Errorf("listing should link to the section's pages %v", string(v.Markdown.Content))
+		}
+
+		if byRoute["/guides/"].generated {
+			t.Error("sections with an _index.md should not be generated")
+		}
+	})
+
+	t.Run("listed titles & summaries are rendered as text in their item", func(t *testing.T) {
+		dir := t.TempDir()
+		fp := filepath.Join(dir, "notes", "xss.md")
+		os.MkdirAll(filepath.Dir(fp), os.ModePerm)
+		content := "+++\ntitle = \"*Bold* [claim]\"\nsummary = \"Runs <script>alert(1)</script> & _more_\"\n+++\n\nBody"
+		if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %v %v", fp, err.Error())
+		}
+
+		views, err := NewViews(dir, "", md.Preview{})
+		if err != nil {
+			t.Fatalf("unable to build views %v", err.Error())
+		}
+
+		var listing *View
+		for _, v := range views {
+			if v.Route() == "/notes/" {
+				listing = v
+			}
+		}
+
+		if listing == nil {
+			t.Fatal("/notes/ should be a generated section")
+		}
+
+		html, _ := listing.Markdown.Render()
+		for _, want := range []string{
+			`<a href="/notes/xss">*Bold* [claim]</a>`,
+			"Runs &lt;script&gt;alert(1)&lt;/script&gt; &amp; _more_</li>",
+		} {
+			if !strings.Contains(string(html), want) {
+				t.Errorf("%s should contain %v", html, want)
+			}
+		}
+
+		if strings.Contains(string(html), "<script>") || strings.Contains(string(html), "</ul>\n\n<p>") {
+			t.Errorf("the summary should be escaped text in the list item %s", html)
+		}
+	})
+
+	t.Run("pages are related to their section", func(t *testing.T) {
+		setup := byRoute["/guides/setup"].Page
+		guides := byRoute["/guides/"].Page
+		advanced := byRoute["/guides/advanced/"].Page
+
+		if setup.Parent != guides {
+			t.Errorf("setup's parent should be guides, got %v", setup.Parent)
+		}
+
+		if advanced.Parent != guides {
+			t.Errorf("advanced's parent should be guides, got %v", advanced.Parent)
+		}
+
+		if guides.Parent != byRoute["/"].Page {
+			t.Error("guides' parent should be the home page")
+		}
+
+		if len(guides.Children) != 3 {
+			t.Errorf("guides should have 3 children but has %v", len(guides.Children))
+		}
+
+		if len(setup.Siblings()) != 2 {
+			t.Errorf("setup should have 2 siblings but has %v", len(setup.Siblings()))
+		}
+	})
+
+	t.Run("breadcrumbs lead from the root to the page", func(t *testing.T) {
+		crumbs := byRoute["/guides/advanced/tuning"].Page.Breadcrumbs()
+		titles := []string{}
+		for _, c := range crumbs {
+			titles = append(titles, c.Title)
+		}
+
+		want := "Home > All Guides > Advanced > Tuning"
+		if got := strings.Join(titles, " > "); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("links between content files are rewritten to routes", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		b := bytes.Buffer{}
+		v := byRoute["/guides/setup"]
+		v.GetTemplate()
+
+		if err := v.Render(&b, &conf); err != nil {
+			t.Fatalf("unable to render %v", err.Error())
+		}
+
+		for _, want := range []string{`href="/guides/deploy#steps"`, `href="/guides/advanced/tuning"`} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("rendered page should contain %v", want)
+			}
+		}
+
+		if len(v.Warnings) != 0 {
+			t.Errorf("there should be no warnings %v", v.Warnings)
+		}
+	})
+
+	t.Run("links to missing files are warnings, or errors in strict mode", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		v := byRoute["/blog/first-post"]
+		v.GetTemplate()
+
+		if err := v.Render(&bytes.Buffer{}, &conf); err != nil {
+			t.Fatalf("warnings should not fail the render %v", err.Error())
+		}
+
+		if len(v.Warnings) != 1 {
+			t.Fatalf("there should be 1 warning, got %v", v.Warnings)
+		}
+
+		conf.Build.Strict = true
+		err := v.Render(&bytes.Buffer{}, &conf)
+		if err == nil || !strings.Contains(err.Error(), "first-post.md") {
+			t.Errorf("strict mode should fail with the source file %v", err)
+		}
+	})
+
+	t.Run("pages are minified when enabled", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		v := byRoute["/guides/setup"]
+		v.GetTemplate()
+
+		full, minified := bytes.Buffer{}, bytes.Buffer{}
+		v.Render(&full, &conf)
+
+		conf.Build.Minify = true
+		if err := v.Render(&minified, &conf); err != nil {
+			t.Fatalf("unable to render %v", err.Error())
+		}
+
+		if minified.Len() >= full.Len() {
+			t.Errorf("page should be smaller, got %v bytes from %v", minified.Len(), full.Len())
+		}
+
+		if !strings.Contains(minified.String(), `href="/guides/deploy#steps"`) {
+			t.Error("minified page should keep its links")
+		}
+	})
+
+	t.Run("nested pages are built into subdirectories", func(t *testing.T) {
+		conf := config.NewDefaultConfig()
+		conf.Build.BuildDir = t.TempDir()
+
+		route, err := byRoute["/guides/advanced/tuning"].BuildHTMLFileContents(&conf)
+		if err != nil {
+			t.Fatalf("unable to build page %v", err.Error())
+		}
+
+		if route != "/guides/advanced/tuning" {
+			t.Errorf("got %v, want /guides/advanced/tuning", route)
+		}
+
+		if _, err := os.Stat(fmt.Sprintf("%v/guides/advanced/tuning.html", conf.Build.BuildDir)); err != nil {
+			t.Errorf("page should have been written %v", err.Error())
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package view
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/desertthunder/documango/internal/config"
+)
+
+// type Page is the template facing description of a single view.
+// Every page in the site is available to templates through .Site.Pages
+// and the page being rendered through .Page
+type Page struct {
+	Title   string
+	URL     string
+	Date    time.Time
+	Tags    []string
+	Section string
+	Summary string
+	Weight  int
+	// Length of the page, see md.Stats. Summary is set from the page's
+	// content when its frontmatter doesn't set it, and Truncated when
+	// it doesn't contain the whole page.
+	WordCount   int
+	ReadingTime int
+	Truncated   bool
+	// Relations
+	IsSection bool
+	Parent    *Page
+	Children  []*Page
+	Prev      *Page
+	Next      *Page
+
+	navTitle   string
+	menus      []string
+	navExclude bool
+	sortBy     string
+}
+
+// type Site is the collection of every page that is built along
+// with the metadata from the [meta] table in config.toml
+type Site struct {
+	Meta config.Meta
+	// Env is the environment selected with --env, ex. production
+	Env   string
+	Pages []*Page
+}
+
+// function WithSite creates a Page for each view and stores a
+// reference to the shared Site in each one. It expects routes to
+// have been set by WithNavigation.
+//
+// Pages are linked to the index page of their section (Parent) and
+// section indexes to the pages & subsections they contain (Children).
+func WithSite(views []*View, contentDir string) []*View {
+	site := &Site{Pages: make([]*Page, 0, len(views))}
+	sections := map[string]*Page{}
+
+	for _, v := range views {
+		dir, name := splitPath(v.Path)
+		p := &Page{
+			Title:     Caser.String(v.Name()),
+			URL:       v.Route(),
+			Section:   section(contentDir, v.Markdown.FilePath),
+			IsSection: name == "index",
+		}
+
+		if p.IsSection {
+			sections[dir] = p
+
+			if dir == "" {
+				p.Title = "Home"
+			} else {
+				_, p.Title = splitPath(dir)
+				p.Title = Caser.String(p.Title)
+			}
+		}
+
+		if fm := v.Markdown.Frontmatter; fm != nil {
+			if fm.Title != "" {
+				p.Title = fm.Title
+			}
+
+			p.Date = fm.Date
+			p.Tags = fm.Tags
+			p.Weight = fm.PageWeight()
+			p.sortBy = fm.SortBy
+			p.navTitle = fm.NavTitle
+			p.menus = fm.Menus
+			p.navExclude = fm.NavExclude
+		}
+
+		v.Page = p
+		v.Site = site
+		v.UpdateStats()
+		site.Pages = append(site.Pages, p)
+	}
+
+	for _, v := range views {
+		dir, _ := splitPath(v.Path)
+		if v.Page.IsSection {
+			if dir == "" {
+				continue
+			}
+
+			dir, _ = splitPath(dir)
+		}
+
+		dirs := ancestors(dir)
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if parent, ok := sections[dirs[i]]; ok {
+				v.Page.Parent = parent
+				parent.Children = append(parent.Children, v.Page)
+				break
+			}
+		}
+	}
+
+	for _, p := range sections {
+		p.sortChildren()
+	}
+
+	return WithPrevNext(views)
+}
+
+// function UpdateStats counts the words of the view's markdown and
+// extracts its summary, reporting whether they changed. Generated
+// section pages have no stats.
+func (v *View) UpdateStats() bool {
+	if v.Page == nil || v.generated {
+		return false
+	}
+
+	stats := v.Markdown.Stats()
+	summary := stats.Summary
+	truncated := stats.Truncated
+	if fm := v.Markdown.Frontmatter; fm != nil && fm.Summary != "" {
+		summary, truncated = fm.Summary, true
+	}
+
+	p := v.Page
+	changed := p.WordCount != stats.Words || p.ReadingTime != stats.ReadingTime ||
+		p.Summary != summary || p.Truncated != truncated
+
+	p.WordCount, p.ReadingTime = stats.Words, stats.ReadingTime
+	p.Summary, p.Truncated = summary, truncated
+	return changed
+}
+
+// function Siblings are the other pages in the same section
+func (p *Page) Siblings() []*Page {
+	siblings := []*Page{}
+	if p.Parent == nil {
+		return siblings
+	}
+
+	for _, c := range p.Parent.Children {
+		if c != p {
+			siblings = append(siblings, c)
+		}
+	}
+
+	return siblings
+}
+
+// function Breadcrumbs is the trail of section pages leading to
+// (and including) p, starting at the root
+func (p *Page) Breadcrumbs() []*Page {
+	trail := []*Page{}
+	for c := p; c != nil; c = c.Parent {
+		trail = append([]*Page{c}, trail...)
+	}
+
+	return trail
+}
+
+// function withConfig returns a copy of the site with the
+// metadata & environment of the provided config
+func (s *Site) withConfig(c *config.Config) *Site {
+	site := Site{}
+	if s != nil {
+		site = *s
+	}
+
+	site.Meta = c.Metadata
+	site.Env = c.Env
+	return &site
+}
+
+// function section is the directory a content file lives in,
+// relative to the content directory. Pages at the root of the
+// content directory have no section.
+func section(contentDir, fp string) string {
+	rel, err := filepath.Rel(contentDir, filepath.Dir(fp))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,104 @@
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/md"
+	"github.com/desertthunder/documango/internal/utils"
+)
+
+func TestSite(t *testing.T) {
+	root := utils.FindWDRoot()
+	contentDir := fmt.Sprintf("%v/example/docs", root)
+	conf, err := config.OpenConfig(fmt.Sprintf("%v/example/config.toml", root))
+	if err != nil {
+		t.Fatalf("example config should be valid %v", err.Error())
+	}
+
+	views, err := NewViews(contentDir, "", md.Preview{})
+	if err != nil && len(views) == 0 {
+		t.Fatalf("unable to build views %v", err.Error())
+	}
+
+	t.Run("every view shares a site with a page for each view", func(t *testing.T) {
+		for _, v := range views {
+			if v.Site == nil || v.Page == nil {
+				t.Fatalf("%v should have a site and a page", v.Name())
+			}
+
+			if len(v.Site.Pages) != len(views) {
+				t.Errorf("there should be %v pages but there are %v", len(views), len(v.Site.Pages))
+			}
+
+			if v.Page.URL != v.Route() {
+				t.Errorf("page url %v should match route %v", v.Page.URL, v.Route())
+			}
+		}
+	})
+
+	t.Run("pages are populated from frontmatter", func(t *testing.T) {
+		for _, v := range views {
+			fm := v.Markdown.Frontmatter
+			if fm != nil && fm.Title != "" && v.Page.Title != fm.Title {
+				t.Errorf("got %v, want %v", v.Page.Title, fm.Title)
+			}
+		}
+	})
+
+	t.Run("pages have a word count, reading time & summary", func(t *testing.T) {
+		for _, v := range views {
+			if v.generated {
+				continue
+			}
+
+			stats := v.Markdown.Stats()
+			if v.Page.WordCount != stats.Words || v.Page.ReadingTime != stats.ReadingTime {
+				t.Errorf("%v: got %v words (%v min), want %+v", v.Path, v.Page.WordCount, v.Page.ReadingTime, stats)
+			}
+
+			if v.Page.Summary == "" && stats.Words > 0 {
+				t.Errorf("%v should have a summary", v.Path)
+			}
+		}
+
+		v := views[0]
+		v.Markdown = &md.MD{Content: []byte("Changed")}
+		if !v.UpdateStats() || v.Page.Summary != "Changed" || v.UpdateStats() {
+			t.Errorf("UpdateStats should report changes, got %+v", v.Page)
+		}
+	})
+
+	t.Run("templates can range over site pages & metadata", func(t *testing.T) {
+		v := views[0]
+		v.GetTemplate()
+		v.Templ, _ = v.Templ.Parse(`{{ .Site.Meta.Name }}{{ range .Site.Pages }}|{{ .URL }}{{ end }}`)
+
+		b := bytes.Buffer{}
+		if err := v.Render(&b, conf); err != nil {
+			t.Fatalf("unable to render %v", err.Error())
+		}
+
+		got := b.String()
+		if !strings.HasPrefix(got, conf.Metadata.Name) {
+			t.Errorf("%v should start with the site name", got)
+		}
+
+		if strings.Count(got, "|") != len(views) {
+			t.Errorf("%v should list every page", got)
+		}
+	})
+
+	t.Run("section is the directory relative to the content dir", func(t *testing.T) {
+		if got := section(contentDir, contentDir+"/about.md"); got != "" {
+			t.Errorf("root pages should not have a section, got %v", got)
+		}
+
+		if got := section(contentDir, contentDir+"/guides/setup.md"); got != "guides" {
+			t.Errorf("got %v, want guides", got)
+		}
+	})
+}
@@ -2,16 +2,21 @@ package view
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/desertthunder/documango/internal/config"
+	"github.com/desertthunder/documango/internal/images"
 	"github.com/desertthunder/documango/internal/md"
+	"github.com/desertthunder/documango/internal/minifier"
 	"github.com/desertthunder/documango/internal/utils"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -37,6 +42,18 @@ type Context struct {
 	Theme     string
 	DocTitle  string
 	PageTitle string
+	// Site wide & page attributes
+	Site        *Site
+	Page        *Page
+	Menus       Menus
+	Breadcrumbs []*Page
+	Prev        *Page
+	Next        *Page
+	// Math is set when the page opts into math typesetting, with
+	// math: true in its frontmatter or markdown.math in the config
+	Math bool
+	// Mermaid is set when the page contains mermaid diagrams
+	Mermaid bool
 }
 
 type View struct {
@@ -46,10 +63,28 @@ type View struct {
 	templateDir string
 	Templ       *template.Template
 	Links       []*NavLink
+	Page        *Page
+	Site        *Site
+	// Previous & next pages in the view's section
+	Prev *Page
+	Next *Page
+	// Warnings found the last time the view was rendered
+	Warnings []md.Warning
+	// Preview is why the page isn't published (ex. md.Draft) when it's
+	// included by --drafts or --future
+	Preview []string
+	// generated views are section pages without an index file
+	generated bool
 }
 
-func NewViews(contentDir, templateDir string) ([]*View, error) {
-	mdFiles, err := md.ReadContentDirectory(contentDir, templateDir)
+// function NewViews creates a view for each published markdown file in
+// the content dir, and the unpublished files included by preview
+func NewViews(contentDir, templateDir string, preview md.Preview) ([]*View, error) {
+	if preview.Now.IsZero() {
+		preview.Now = time.Now()
+	}
+
+	mdFiles, err := md.ReadContentDirectory(contentDir, templateDir, preview)
 	if err != nil && len(mdFiles) == 0 {
 		return []*View{}, err
 	}
@@ -57,33 +92,101 @@ func NewViews(contentDir, templateDir string) ([]*View, error) {
 	views := make([]*View, 0, len(mdFiles))
 	for _, m := range mdFiles {
 		views = append(views, &View{
-			Path:     m.FilePath,
-			Markdown: m,
+			Path:        relativePath(contentDir, m.FilePath),
+			Markdown:    m,
+			templateDir: templateDir,
+			Preview:     m.Frontmatter.Unpublished(preview.Now),
 		})
 	}
 
-	return WithNavigation(views), err
+	views = WithSections(views, contentDir, templateDir)
+
+	if templateDir != "" {
+		for _, v := range views {
+			v.Markdown.ShortcodeDir = filepath.Join(templateDir, "shortcodes")
+		}
+	}
+
+	views = WithLinks(WithNavigation(views))
+
+	return WithListings(WithSite(views, contentDir)), err
+}
+
+// function WithLinks lets each view's markdown rewrite links to
+// other content files to the routes set by WithNavigation
+func WithLinks(views []*View) []*View {
+	routes := make(map[string]string, len(views))
+	for _, v := range views {
+		routes[filepath.Clean(v.Markdown.FilePath)] = v.Route()
+	}
+
+	resolver := func(fp string) (string, bool) {
+		route, ok := routes[filepath.Clean(fp)]
+		return route, ok
+	}
+
+	for _, v := range views {
+		v.Markdown.Resolver = resolver
+	}
+
+	return views
+}
+
+// function WithImages processes the images of each view's markdown with
+// processor, which is created once for a build of the site (see
+// NewImageProcessor). Views without one leave their images untouched.
+func WithImages(views []*View, processor *images.Processor) []*View {
+	for _, v := range views {
+		v.Markdown.Images = processor.Resolve
+	}
+
+	return views
+}
+
+// function WithOptions renders the markdown of each view with opts,
+// which are parsed once for a build of the site from the validated
+// config (see config.MarkdownOptions.Parse)
+func WithOptions(views []*View, opts md.Options) []*View {
+	for _, v := range views {
+		v.Markdown.Options = &opts
+	}
+
+	return views
+}
+
+// function NewImageProcessor creates the image processor for the site
+// described by conf, serving images from the path portion of meta.URL
+func NewImageProcessor(conf *config.Config) *images.Processor {
+	return images.NewProcessor(conf, func(p string) string { return relURL(conf, p) })
 }
 
 // function WithNavigation populates a NavLink
 // list in the View struct to build context when
-// rendering the layout
+// rendering the layout.
+//
+// A view's Path is expected to be its path relative to the content
+// directory, without an extension. Index files (index.md, README.md
+// & _index.md) are routed to their directory.
 func WithNavigation(views []*View) []*View {
 	links := make([]*NavLink, len(views))
 	for i, v := range views {
-		path := strings.ToLower(v.Name())
-		route := fmt.Sprintf("/%v", path)
-		l := NavLink{Name: Caser.String(path)}
-
-		if path == "index" || path == "readme" {
-			route = "/"
-			path = "index"
-
-			l.Name = "Home"
+		path := strings.ToLower(v.Path)
+		dir, name := splitPath(path)
+		l := NavLink{Name: Caser.String(name)}
+
+		if isIndex(name) {
+			path = strings.TrimPrefix(dir+"/index", "/")
+
+			if dir == "" {
+				l.Name = "Home"
+			} else {
+				_, l.Name = splitPath(dir)
+				l.Name = Caser.String(l.Name)
+			}
 		}
 
 		v.Path = path
-		l.Path = route
+		l.Path = v.Route()
 
 		links[i] = &l
 	}
@@ -103,11 +206,15 @@ func WithNavigation(views []*View) []*View {
 //  2. {template_dir}/{name}.html
 //  3. {template_dir}/base.html
 //  4. DefaultLayoutTemplate (/cmd/build/views/base.html)
+//
+// Every template is parsed with the functions in FuncMap.
 func (v *View) GetTemplate() error {
-	_, err := os.ReadDir(v.templateDir)
-	if err != nil {
+	var err error
+	v.Templ = nil
+
+	if _, dirErr := os.ReadDir(v.templateDir); v.templateDir != "" && dirErr == nil {
 		if v.Markdown.Frontmatter != nil && v.Markdown.Frontmatter.Layout != "" {
-			v.Templ, err = template.ParseGlob(fmt.Sprintf("%v/%v.html", v.templateDir, v.Markdown.Frontmatter.Layout))
+			v.Templ, err = parseTemplate(fmt.Sprintf("%v/%v.html", v.templateDir, v.Markdown.Frontmatter.Layout))
 
 			if err != nil {
 				err = fmt.Errorf("layout (%v) defined in frontmatter for %v not found: %v", v.Markdown.Frontmatter.Layout, v.Name(), err.Error())
@@ -115,7 +222,16 @@ func (v *View) GetTemplate() error {
 		}
 
 		for _, p := range []string{v.Name(), "base"} {
-			v.Templ, err = template.ParseGlob(fmt.Sprintf("%v/%v.html", v.templateDir, p))
+			if v.Templ != nil {
+				break
+			}
+
+			fp := fmt.Sprintf("%v/%v.html", v.templateDir, p)
+			if _, statErr := os.Stat(fp); statErr != nil {
+				continue
+			}
+
+			v.Templ, err = parseTemplate(fp)
 			if err != nil {
 				err = fmt.Errorf("unable to parse parse glob for %v: %v", p, err.Error())
 			}
@@ -123,20 +239,62 @@ func (v *View) GetTemplate() error {
 	}
 
 	if v.Templ == nil {
-		v.Templ, err = template.New("layout").Parse(string(DefaultLayoutTemplate))
+		var defaultErr error
+		v.Templ, defaultErr = template.New("layout").Funcs(FuncMap(nil)).Parse(string(DefaultLayoutTemplate))
+		if defaultErr != nil {
+			err = defaultErr
+		}
 	}
 
 	return err
 }
 
-// func Render executes and writes the template with included frontmatter
+// function CustomLayout reports whether the view was last rendered with
+// a template from the template dir, rather than DefaultLayoutTemplate
+func (v View) CustomLayout() bool {
+	return v.Templ != nil && v.Templ.Name() != "layout"
+}
+
+// function parseTemplate parses the template file at fp with the
+// template function library registered
+func parseTemplate(fp string) (*template.Template, error) {
+	name := fp[strings.LastIndex(fp, "/")+1:]
+	return template.New(name).Funcs(FuncMap(nil)).ParseFiles(fp)
+}
+
+// func Render executes and writes the template with included frontmatter.
+// Problems found in the markdown are stored in Warnings and, when the
+// strict option is set, returned as an error instead of rendering. The
+// page is minified when build.minify is set.
 func (v *View) Render(w io.Writer, conf *config.Config) error {
+	contents, warnings := v.Markdown.Render()
+	v.Warnings = warnings
+
+	if conf.Build.Strict && len(warnings) > 0 {
+		errs := make([]error, 0, len(warnings))
+		for _, w := range warnings {
+			errs = append(errs, w)
+		}
+
+		return errors.Join(errs...)
+	}
+
 	templ_ctx := Context{
-		Contents:  template.HTML(v.Markdown.HTML()),
+		Contents:  template.HTML(contents),
 		Theme:     "dark",
 		DocTitle:  conf.Metadata.Name,
 		PageTitle: conf.Metadata.Name,
 		Links:     v.Links,
+		Site:      v.Site.withConfig(conf),
+		Page:      v.Page,
+		Menus:     BuildMenus(conf, v.Site),
+		Prev:      v.Prev,
+		Next:      v.Next,
+		Mermaid:   md.HasMermaid(contents),
+	}
+
+	if v.Page != nil {
+		templ_ctx.Breadcrumbs = v.Page.Breadcrumbs()
 	}
 
 	if v.Markdown.Frontmatter != nil {
@@ -144,15 +302,32 @@ func (v *View) Render(w io.Writer, conf *config.Config) error {
 			templ_ctx.DocTitle = fmt.Sprintf("%v | %v", v.Markdown.Frontmatter.Title, templ_ctx.DocTitle)
 		}
 		templ_ctx.PageTitle = v.Markdown.Frontmatter.Title
+		templ_ctx.Math = v.Markdown.Frontmatter.Math
+	}
+
+	templ_ctx.Math = templ_ctx.Math || conf.Markdown.Math
+
+	if !conf.Build.Minify {
+		return v.Templ.Funcs(FuncMap(conf)).Execute(w, templ_ctx)
 	}
 
-	err := v.Templ.Execute(w, templ_ctx)
+	b := bytes.Buffer{}
+	if err := v.Templ.Funcs(FuncMap(conf)).Execute(&b, templ_ctx); err != nil {
+		return err
+	}
 
+	minified, err := minifier.Minify("text/html", b.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(minified)
 	return err
 }
 
 func (v *View) BuildHTMLFileContents(c *config.Config) (string, error) {
-	p := fmt.Sprintf("%v/%v.html", c.Options.BuildDir, v.Path)
+	p := fmt.Sprintf("%v/%v.html", c.Build.BuildDir, v.Path)
+	utils.CreateDir(filepath.Dir(p))
 	f, err := os.Create(p)
 	if err != nil {
 		return v.Path, err
@@ -175,11 +350,21 @@ func (v *View) BuildHTMLFileContents(c *config.Config) (string, error) {
 		return "", fmt.Errorf("unable to render %v \n%v", v.Name(), err.Error())
 	}
 
+	return v.Route(), err
+}
+
+// function Route is the URL path a view is served from. Section
+// indexes are served from their directory, ex. /guides/
+func (v View) Route() string {
 	if v.Path == "index" {
-		return "/", err
-	} else {
-		return "/" + v.Path, err
+		return "/"
 	}
+
+	if strings.HasSuffix(v.Path, "/index") {
+		return "/" + strings.TrimSuffix(v.Path, "index")
+	}
+
+	return "/" + v.Path
 }
 
 func (v View) Name() string {
@@ -4,6 +4,9 @@
 // Commands:
 //
 //	documango run		 - starts the server
+//	documango build		 - builds a directory of pages for your files
+//	documango check		 - checks the built site for broken links
+//	documango config	 - prints the effective config & its sources
 //
 // In Progress:
 //
@@ -12,15 +15,16 @@
 // Future:
 //
 //	documango new [type] - create a docs dir and frontmatter schema
-//	documango build		 - builds a directory of pages for your files
 //	documango deploy 	 - deploy to gh pages, neocities, cloudflare
 package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/desertthunder/documango/cmd/build"
+	"github.com/desertthunder/documango/cmd/check"
 	"github.com/desertthunder/documango/cmd/server"
 	"github.com/desertthunder/documango/internal/config"
 	"github.com/desertthunder/documango/internal/logs"
@@ -38,7 +42,7 @@ var rootCommand = &cli.Command{
 	Version:     "0.2.0",
 	Description: `a cli to quickly generate a static site from a folder of markdown files`,
 	Usage:       "generate a static site from a collection of markdown files",
-	Flags: config.MergeFlags(
+	Flags: append(config.MergeFlags(
 		&cli.StringFlag{
 			Name:        "file",
 			Aliases:     []string{"f"},
@@ -46,14 +50,41 @@ var rootCommand = &cli.Command{
 			Value:       "config.toml",
 			DefaultText: "default text",
 		}, false),
-	Commands: []*cli.Command{server.ServerCommand, build.BuildCommand},
+		&cli.StringFlag{
+			Name:    "env",
+			Aliases: []string{"e"},
+			Usage:   "environment overlay to apply, ex. production for config.production.toml or [env.production]",
+			Sources: cli.EnvVars(config.EnvPrefix + "ENV"),
+		}),
+	Commands: []*cli.Command{server.ServerCommand, build.BuildCommand, check.CheckCommand, config.ConfigCommand},
 	Before:   setContext,
 }
 
+// function setContext opens the config file with the overlays of the
+// selected --env, layers the environment & root flags over it and
+// stores it in the context. Subcommands apply their own flags with
+// config.ApplyFlags.
+//
+// The config file is optional unless it's passed with --file.
 func setContext(parent context.Context, c *cli.Command) (context.Context, error) {
-	conf := config.OpenConfig(c.String("file"))
+	p := c.String("file")
+	if _, err := os.Stat(p); err != nil && c.IsSet("file") {
+		return parent, fmt.Errorf("unable to open config file %w", err)
+	}
+
+	conf, err := config.OpenEnvConfig(p, c.String("env"))
+	if err != nil {
+		return parent, fmt.Errorf("invalid config\n%w", err)
+	}
+
 	ctx := context.WithValue(parent, config.ConfKey, conf)
 	ctx = context.WithValue(ctx, config.LoggerKey, logger)
+
+	ctx, err = config.ApplyFlags(ctx, c)
+	if err != nil {
+		return ctx, err
+	}
+
 	logger.Debugf("Set context %v", utils.ToJSONString(conf))
 	return ctx, nil
 }
@@ -20,7 +20,9 @@ func TestMain(t *testing.T) {
 		ctx := context.Background()
 		cmd := rootCommand
 		sb := strings.Builder{}
+		writer := cmd.Writer
 		cmd.Writer = &sb
+		t.Cleanup(func() { cmd.Writer = writer })
 
 		args := os.Args[0:1]
 		args = append(args, "--file")
@@ -59,4 +61,32 @@ func TestMain(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("config is layered as defaults < file < env < flags", func(t *testing.T) {
+		t.Setenv("DOCUMANGO_DEV_PORT", "9000")
+		t.Setenv("DOCUMANGO_DEV_CONTENT_DIR", "from-env")
+
+		sb := strings.Builder{}
+		cmd := rootCommand
+		writer := cmd.Writer
+		cmd.Writer = &sb
+		t.Cleanup(func() { cmd.Writer = writer })
+
+		args := []string{os.Args[0], "--file", fmt.Sprintf("%v/%v", base_path, "config.toml"), "config", "--content", "from-flag"}
+		if err := cmd.Run(context.Background(), args); err != nil {
+			t.Fatalf("unable to run config command %v", err.Error())
+		}
+
+		output := sb.String()
+		for _, want := range []string{
+			`port = 9000`, `# env DOCUMANGO_DEV_PORT`,
+			`content_dir = "from-flag"`, `# flag --content`,
+			`template_dir = "views"`, `config.toml`,
+			`quality = 80`, `# default`,
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("output should contain %v\n%v", want, output)
+			}
+		}
+	})
 }